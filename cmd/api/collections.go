@@ -0,0 +1,403 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) createCollectionHandler(res http.ResponseWriter, req *http.Request) {
+	type CreateCollectionDTO struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+
+	body := new(CreateCollectionDTO)
+
+	err := app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	collection := &data.Collection{
+		UserID:      app.contextGetUser(req).ID,
+		Title:       body.Title,
+		Description: body.Description,
+		Visibility:  body.Visibility,
+	}
+
+	// A new collection stays private until the owner decides otherwise
+	if collection.Visibility == "" {
+		collection.Visibility = data.VisibilityPrivate
+	}
+
+	validate := validator.New()
+
+	if data.ValidateCollection(validate, collection); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Collections.Insert(collection)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/collections/%d", collection.ID))
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = collection
+	response.Message = "Collection Created Successfully"
+
+	err = app.writeJSON(res, http.StatusCreated, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// showCollectionsHandler searches the public collections by title
+func (app *application) showCollectionsHandler(res http.ResponseWriter, req *http.Request) {
+	var filters data.Filters
+
+	validate := validator.New()
+	qs := req.URL.Query()
+
+	title := app.readString(qs, "title", "")
+	validate.Check(len(title) <= 100, "title", "title max length is 100 characters")
+
+	filters.Page = app.readInt(qs, "page", 1, validate)
+	filters.PageSize = app.readInt(qs, "page_size", 10, validate)
+	filters.Cursor = app.readString(qs, "cursor", "")
+	filters.IncludeTotal = app.readBool(qs, "include_total", true, validate)
+
+	filters.Sort = app.readString(qs, "sort", "-updated_at")
+	filters.SortSafeList = []string{"id", "title", "created_at", "updated_at", "-id", "-title", "-created_at", "-updated_at"}
+
+	if data.ValidateFilters(validate, &filters); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	collections, paginationMetadata, err := app.models.Collections.GetAllPublic(title, filters)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = collections
+	response.Message = "Collections Fetched Successfully"
+	response.Pagination = &paginationMetadata
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) showCollectionHandler(res http.ResponseWriter, req *http.Request) {
+	collection, ok := app.visibleCollection(res, req)
+	if !ok {
+		return
+	}
+
+	movies, err := app.models.Collections.GetMovies(collection.ID)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+	collection.Movies = movies
+
+	response := data.NewResponse()
+	response.Result = collection
+	response.Message = "Collection Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) updateCollectionHandler(res http.ResponseWriter, req *http.Request) {
+	collection, ok := app.ownedCollection(res, req)
+	if !ok {
+		return
+	}
+
+	type UpdateCollectionDTO struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+
+	body := new(UpdateCollectionDTO)
+
+	err := app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if body.Title != nil {
+		collection.Title = *body.Title
+	}
+
+	if body.Description != nil {
+		collection.Description = *body.Description
+	}
+
+	if body.Visibility != nil {
+		collection.Visibility = *body.Visibility
+	}
+
+	validate := validator.New()
+
+	if data.ValidateCollection(validate, collection); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Collections.Update(collection)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = collection
+	response.Message = "Collection Updated Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deleteCollectionHandler(res http.ResponseWriter, req *http.Request) {
+	collection, ok := app.ownedCollection(res, req)
+	if !ok {
+		return
+	}
+
+	err := app.models.Collections.Delete(collection.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"id": collection.ID}
+	response.Message = fmt.Sprintf("Collection With The Following ID %d has Been Deleted", collection.ID)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// putCollectionMovieHandler adds a movie at the end of the collection,
+// or only updates its note when the movie is already in there
+func (app *application) putCollectionMovieHandler(res http.ResponseWriter, req *http.Request) {
+	collection, ok := app.ownedCollection(res, req)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(req, "movie_id")
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type PutCollectionMovieDTO struct {
+		Note string `json:"note"`
+	}
+
+	body := new(PutCollectionMovieDTO)
+
+	if req.ContentLength != 0 {
+		err = app.readJSON(res, req, &body)
+		if err != nil {
+			app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	validate := validator.New()
+
+	if data.ValidateCollectionNote(validate, body.Note); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	entry, err := app.models.Collections.AddMovie(collection.ID, movieID, body.Note)
+	if errors.Is(err, data.ErrDuplicateCollectionMovie) {
+		entry, err = app.models.Collections.UpdateMovieNote(collection.ID, movieID, body.Note)
+	}
+
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = entry
+	response.Message = "Collection Movie Saved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) moveCollectionMovieHandler(res http.ResponseWriter, req *http.Request) {
+	collection, ok := app.ownedCollection(res, req)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(req, "movie_id")
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type MoveCollectionMovieDTO struct {
+		Position int32 `json:"position"`
+	}
+
+	body := new(MoveCollectionMovieDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	validate := validator.New()
+
+	if validate.Check(body.Position > 0, "position", "position must be greater than zero"); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	entry, err := app.models.Collections.MoveMovie(collection.ID, movieID, body.Position)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = entry
+	response.Message = "Collection Movie Moved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deleteCollectionMovieHandler(res http.ResponseWriter, req *http.Request) {
+	collection, ok := app.ownedCollection(res, req)
+	if !ok {
+		return
+	}
+
+	movieID, err := app.readNamedIDParam(req, "movie_id")
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	err = app.models.Collections.RemoveMovie(collection.ID, movieID)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"collection_id": collection.ID, "movie_id": movieID}
+	response.Message = "Movie Removed From Collection Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// visibleCollection loads the collection of the :id parameter and sends a not found
+// response when it doesn't exist or is private to somebody else
+func (app *application) visibleCollection(res http.ResponseWriter, req *http.Request) (*data.Collection, bool) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return nil, false
+	}
+
+	collection, err := app.models.Collections.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return nil, false
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return nil, false
+	}
+
+	if !collection.VisibleTo(app.contextGetUser(req)) {
+		app.notFoundResponse(res, req)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// ownedCollection is visibleCollection for the edit routes, only the owner may go further
+func (app *application) ownedCollection(res http.ResponseWriter, req *http.Request) (*data.Collection, bool) {
+	collection, ok := app.visibleCollection(res, req)
+	if !ok {
+		return nil, false
+	}
+
+	if collection.UserID != app.contextGetUser(req).ID {
+		app.notPermittedResponse(res, req)
+		return nil, false
+	}
+
+	return collection, true
+}
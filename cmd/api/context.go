@@ -0,0 +1,28 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"context"
+	"net/http"
+)
+
+// Custom type for the request context keys, so they can't collide with keys of other packages
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// contextSetUser returns a copy of the request with the user added to its context
+func (app *application) contextSetUser(req *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(req.Context(), userContextKey, user)
+	return req.WithContext(ctx)
+}
+
+// contextGetUser retrieves the user from the request context. It is only called
+// after the authenticate middleware has run, so a missing value is unexpected and panics
+func (app *application) contextGetUser(req *http.Request) *data.User {
+	user, ok := req.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) showMovieCreditsHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	credits, err := app.models.Credits.GetForMovie(movie.ID)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = credits
+	response.Message = "Credits Fetched Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// createMovieCreditHandler credits an existing person on a movie, director and actor
+// credits show up in the director and actors of the movie right away
+func (app *application) createMovieCreditHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type CreateCreditDTO struct {
+		PersonID      int64  `json:"person_id"`
+		Role          string `json:"role"`
+		CharacterName string `json:"character_name"`
+		BillingOrder  int32  `json:"billing_order"`
+	}
+
+	body := new(CreateCreditDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	credit := &data.Credit{
+		MovieID:       id,
+		PersonID:      body.PersonID,
+		Role:          body.Role,
+		CharacterName: body.CharacterName,
+		BillingOrder:  body.BillingOrder,
+	}
+
+	validate := validator.New()
+
+	if data.ValidateCredit(validate, credit); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Credits.Insert(credit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCredit):
+			validate.AddError("person_id", "this person already has this role on the movie")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrNoRecordsFound):
+			app.redirectMergedMovie(res, req, id)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/credits", credit.MovieID))
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = credit
+	response.Message = "Credit Created Successfully"
+
+	err = app.writeJSON(res, http.StatusCreated, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deleteMovieCreditHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	creditID, err := app.readNamedIDParam(req, "credit_id")
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	err = app.models.Credits.Delete(id, creditID)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"id": creditID}
+	response.Message = fmt.Sprintf("Credit With The Following ID %d has Been Deleted", creditID)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
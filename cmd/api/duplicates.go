@@ -0,0 +1,130 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+// mergeMovieHandler folds the movie in the path into the canonical movie given as "into".
+// Ratings, reviews, credits and everything else move over and the old id redirects from then on.
+func (app *application) mergeMovieHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type MergeMovieDTO struct {
+		Into int64 `json:"into"`
+	}
+
+	body := new(MergeMovieDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	validate := validator.New()
+
+	validate.Check(body.Into > 0, "into", "must be the id of the canonical movie")
+	validate.Check(body.Into != id, "into", "must be another movie")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	duplicate, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	err = app.models.Movie.Merge(id, body.Into)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordsFound):
+			app.notFoundResponse(res, req)
+		case errors.Is(err, data.ErrMergeIntoItself):
+			validate.AddError("into", "must be another movie")
+			app.failedValidationResponse(res, req, validate.Errors)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	movie, err := app.models.Movie.Get(body.Into)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	// The duplicate's poster went with it, unless both shared the same file
+	if duplicate.PosterKey != "" && duplicate.PosterKey != movie.PosterKey {
+		key := duplicate.PosterKey
+		app.background(func() {
+			app.deletePoster(key)
+		})
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+
+	response := data.NewResponse()
+	response.Result = movie
+	response.Message = fmt.Sprintf("Movie With The Following ID %d has Been Merged Into %d", id, movie.ID)
+
+	err = app.writeJSON(res, 200, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// redirectMergedMovie answers a movie which no longer exists with a permanent redirect when it
+// was merged into another one, and with not found otherwise
+func (app *application) redirectMergedMovie(res http.ResponseWriter, req *http.Request, id int64) {
+	if !app.redirectIfMerged(res, req, id) {
+		app.notFoundResponse(res, req)
+	}
+}
+
+// redirectIfMerged redirects to the same path under the movie the given movie was merged
+// into and reports whether it answered the request. GET and HEAD get moved permanently,
+// every other method a permanent redirect which has to be repeated with the same body.
+func (app *application) redirectIfMerged(res http.ResponseWriter, req *http.Request, id int64) bool {
+	movieID, err := app.models.Movie.GetRedirect(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return false
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return true
+	}
+
+	prefix := "/v1/movies/" + httprouter.ParamsFromContext(req.Context()).ByName("id")
+	location := fmt.Sprintf("/v1/movies/%d%s", movieID, strings.TrimPrefix(req.URL.Path, prefix))
+	if req.URL.RawQuery != "" {
+		location += "?" + req.URL.RawQuery
+	}
+
+	status := http.StatusPermanentRedirect
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+
+	http.Redirect(res, req, location, status)
+	return true
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"api.go-rifqio.my.id/internal/data"
 	"fmt"
 	"net/http"
 )
@@ -49,7 +50,46 @@ func (app *application) editConflictResponse(res http.ResponseWriter, req *http.
 	app.errorResponse(res, req, http.StatusConflict, message)
 }
 
+func (app *application) duplicateMovieResponse(res http.ResponseWriter, req *http.Request, candidates []*data.DuplicateCandidate) {
+	message := envelope{
+		"message":    "The movie looks like one which already exists, resend with ?force=true to create it anyway",
+		"candidates": candidates,
+	}
+	app.errorResponse(res, req, http.StatusConflict, message)
+}
+
 func (app *application) limitExceededResponse(res http.ResponseWriter, req *http.Request) {
 	message := "Error too many request"
 	app.errorResponse(res, req, http.StatusTooManyRequests, message)
 }
+
+func (app *application) invalidCredentialsResponse(res http.ResponseWriter, req *http.Request) {
+	message := "Invalid authentication credentials"
+	app.errorResponse(res, req, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(res http.ResponseWriter, req *http.Request) {
+	// Let the client know a bearer token is expected
+	res.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := "Invalid or missing authentication token"
+	app.errorResponse(res, req, http.StatusUnauthorized, message)
+}
+
+func (app *application) authenticationRequiredResponse(res http.ResponseWriter, req *http.Request) {
+	message := "You must be authenticated to access this resource"
+	app.errorResponse(res, req, http.StatusUnauthorized, message)
+}
+
+func (app *application) notPermittedResponse(res http.ResponseWriter, req *http.Request) {
+	message := "Your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(res, req, http.StatusForbidden, message)
+}
+
+// serverBusyResponse asks the client to retry a request the server can't take on right now
+func (app *application) serverBusyResponse(res http.ResponseWriter, req *http.Request, retryAfter int) {
+	res.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+
+	message := "The server is busy, please retry shortly"
+	app.errorResponse(res, req, http.StatusServiceUnavailable, message)
+}
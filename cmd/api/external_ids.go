@@ -0,0 +1,138 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// lookupMovieHandler finds a movie by its id in an external source, like ?source=imdb&id=tt0034583
+func (app *application) lookupMovieHandler(res http.ResponseWriter, req *http.Request) {
+	qs := req.URL.Query()
+
+	source := app.readString(qs, "source", "")
+	externalID := app.readString(qs, "id", "")
+
+	validate := validator.New()
+
+	if data.ValidateExternalID(validate, source, externalID); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	movie, err := app.models.Movie.GetByExternalID(source, externalID)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+
+	response := data.NewResponse()
+	response.Result = movie
+	response.Message = "Movie Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// upsertExternalMovieHandler saves a whole movie by its id in an external source so imports
+// don't need to know our ids. The movie mapped to the external id is replaced, or created
+// and mapped when there is none yet.
+func (app *application) upsertExternalMovieHandler(res http.ResponseWriter, req *http.Request) {
+	params := httprouter.ParamsFromContext(req.Context())
+	source := params.ByName("source")
+	externalID := params.ByName("external_id")
+
+	type UpsertMovieDTO struct {
+		Title       string           `json:"title"`
+		Year        int32            `json:"year"`
+		Runtime     int32            `json:"runtime"`
+		Genres      []string         `json:"genres"`
+		Director    string           `json:"director"`
+		Actors      []string         `json:"actors"`
+		Plot        string           `json:"plot"`
+		PosterURL   string           `json:"poster_url"`
+		ExternalIDs data.ExternalIDs `json:"external_ids"`
+	}
+
+	body := new(UpsertMovieDTO)
+
+	err := app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	movie := &data.Movie{
+		Title:       body.Title,
+		Year:        body.Year,
+		Runtime:     body.Runtime,
+		Genres:      body.Genres,
+		Director:    body.Director,
+		Actors:      body.Actors,
+		Plot:        body.Plot,
+		PosterURL:   body.PosterURL,
+		ExternalIDs: body.ExternalIDs,
+	}
+
+	taxonomy, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	validate := validator.New()
+
+	data.ValidateExternalID(validate, source, externalID)
+	data.ValidateMovie(validate, movie, taxonomy)
+	data.ValidateExternalIDs(validate, movie.ExternalIDs)
+	if id, ok := movie.ExternalIDs[source]; ok {
+		validate.Check(id == externalID, "external_ids."+source, "must match the id in the path")
+	}
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	created, err := app.models.Movie.UpsertByExternalID(source, externalID, movie)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateExternalID) {
+			validate.AddError("external_ids", "an external id is already mapped to another movie")
+			app.failedValidationResponse(res, req, validate.Errors)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+
+	response := data.NewResponse()
+	response.Result = movie
+	response.Message = "Movie Updated Successfully"
+
+	if created {
+		response.StatusCode = http.StatusCreated
+		response.Message = "Movie Created Successfully"
+	}
+
+	err = app.writeJSON(res, response.StatusCode, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
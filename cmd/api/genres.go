@@ -0,0 +1,231 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// showGenresHandler lists the genre taxonomy with the number of movies in each genre
+func (app *application) showGenresHandler(res http.ResponseWriter, req *http.Request) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = genres
+	response.Message = "Genres Fetched Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) showGenreHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	genre, err := app.models.Genres.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = genre
+	response.Message = "Genre Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) createGenreHandler(res http.ResponseWriter, req *http.Request) {
+	type CreateGenreDTO struct {
+		Slug    string   `json:"slug"`
+		Name    string   `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	body := new(CreateGenreDTO)
+
+	err := app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	genre := &data.Genre{
+		Slug:    body.Slug,
+		Name:    body.Name,
+		Aliases: body.Aliases,
+	}
+
+	// The slug defaults to the slugified name
+	if genre.Slug == "" {
+		genre.Slug = data.Slugify(genre.Name)
+	}
+
+	if genre.Aliases == nil {
+		genre.Aliases = []string{}
+	}
+
+	validate := validator.New()
+
+	if data.ValidateGenre(validate, genre); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(genre)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateGenre) {
+			validate.AddError("slug", "the slug or one of the aliases already belongs to another genre")
+			app.failedValidationResponse(res, req, validate.Errors)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/genres/%d", genre.ID))
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = genre
+	response.Message = "Genre Created Successfully"
+
+	err = app.writeJSON(res, http.StatusCreated, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) updateGenreHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type UpdateGenreDTO struct {
+		Slug    *string  `json:"slug"`
+		Name    *string  `json:"name"`
+		Aliases []string `json:"aliases"`
+	}
+
+	body := new(UpdateGenreDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	genre, err := app.models.Genres.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	if body.Slug != nil {
+		genre.Slug = *body.Slug
+	}
+
+	if body.Name != nil {
+		genre.Name = *body.Name
+	}
+
+	if body.Aliases != nil {
+		genre.Aliases = body.Aliases
+	}
+
+	validate := validator.New()
+
+	if data.ValidateGenre(validate, genre); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			validate.AddError("slug", "the slug or one of the aliases already belongs to another genre")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(res, req)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = genre
+	response.Message = "Genre Updated Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// deleteGenreHandler removes a genre, ?replace_with= merges it into another genre
+// which is required while movies still use it
+func (app *application) deleteGenreHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	replacement := data.Slugify(app.readString(req.URL.Query(), "replace_with", ""))
+
+	err = app.models.Genres.Delete(id, replacement)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrGenreInUse):
+			validate := validator.New()
+			validate.AddError("replace_with", "the genre is still used by movies, provide a genre to merge it into")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrNoRecordsFound):
+			app.notFoundResponse(res, req)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"id": id}
+	response.Message = fmt.Sprintf("Genre With The Following ID %d has Been Deleted", id)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
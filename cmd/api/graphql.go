@@ -0,0 +1,807 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const graphqlContextKey = contextKey("graphql")
+
+// graphqlError is an error with a code and details the client can act on, they end
+// up in the extensions of the error in the response
+type graphqlError struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]interface{} {
+	return e.extensions
+}
+
+func graphqlValidationError(errors map[string]string) error {
+	return &graphqlError{
+		message:    "Failed validation",
+		extensions: map[string]interface{}{"code": "BAD_USER_INPUT", "errors": errors},
+	}
+}
+
+func graphqlNotFoundError() error {
+	return &graphqlError{
+		message:    "The requested resource could not be found",
+		extensions: map[string]interface{}{"code": "NOT_FOUND"},
+	}
+}
+
+func graphqlEditConflictError() error {
+	return &graphqlError{
+		message:    "Unable to update the record due to an edit conflict, please try again",
+		extensions: map[string]interface{}{"code": "EDIT_CONFLICT"},
+	}
+}
+
+// loader batches the lookups of one GraphQL request. Resolvers queue their id and
+// return a thunk, the executor calls the thunks once every field of the level is
+// resolved so the first call fetches the ids of the whole level at once. The executor
+// resolves a request on one goroutine, so a loader is never used concurrently.
+type loader[V any] struct {
+	fetch   func(ids []int64) (map[int64]V, error)
+	pending []int64
+	values  map[int64]V
+	errs    map[int64]error
+}
+
+func newLoader[V any](fetch func(ids []int64) (map[int64]V, error)) *loader[V] {
+	return &loader[V]{
+		fetch:  fetch,
+		values: make(map[int64]V),
+		errs:   make(map[int64]error),
+	}
+}
+
+func (l *loader[V]) load(id int64) func() (interface{}, error) {
+	if _, ok := l.values[id]; !ok {
+		l.pending = append(l.pending, id)
+	}
+
+	return func() (interface{}, error) {
+		if len(l.pending) > 0 {
+			l.dispatch()
+		}
+
+		if err, ok := l.errs[id]; ok {
+			return nil, err
+		}
+
+		value, ok := l.values[id]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
+
+func (l *loader[V]) dispatch() {
+	seen := make(map[int64]bool, len(l.pending))
+	ids := make([]int64, 0, len(l.pending))
+	for _, id := range l.pending {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	l.pending = nil
+
+	values, err := l.fetch(ids)
+	for _, id := range ids {
+		if err != nil {
+			l.errs[id] = err
+		} else if value, ok := values[id]; ok {
+			l.values[id] = value
+		}
+	}
+}
+
+// graphqlRequest is the state of one GraphQL request, resolvers reach it through the context
+type graphqlRequest struct {
+	req     *http.Request
+	user    *data.User
+	locales []string
+	movies  *loader[*data.Movie]
+	credits *loader[[]*data.Credit]
+	ratings *loader[*data.Rating]
+}
+
+func (app *application) newGraphqlRequest(req *http.Request) *graphqlRequest {
+	gr := &graphqlRequest{
+		req:     req,
+		user:    app.contextGetUser(req),
+		locales: app.readLocales(req),
+	}
+
+	gr.movies = newLoader(func(ids []int64) (map[int64]*data.Movie, error) {
+		movies, err := app.models.Movie.GetMany(ids)
+		if err != nil {
+			return nil, app.graphqlInternalError(gr, err)
+		}
+
+		err = app.models.Translations.Localize(gr.locales, movies...)
+		if err != nil {
+			return nil, app.graphqlInternalError(gr, err)
+		}
+
+		byID := make(map[int64]*data.Movie, len(movies))
+		for _, movie := range movies {
+			byID[movie.ID] = movie
+		}
+		return byID, nil
+	})
+
+	gr.credits = newLoader(func(ids []int64) (map[int64][]*data.Credit, error) {
+		credits, err := app.models.Credits.GetForMovies(ids)
+		if err != nil {
+			return nil, app.graphqlInternalError(gr, err)
+		}
+
+		byMovie := make(map[int64][]*data.Credit, len(ids))
+		for _, id := range ids {
+			byMovie[id] = []*data.Credit{}
+		}
+		for _, credit := range credits {
+			byMovie[credit.MovieID] = append(byMovie[credit.MovieID], credit)
+		}
+		return byMovie, nil
+	})
+
+	gr.ratings = newLoader(func(ids []int64) (map[int64]*data.Rating, error) {
+		ratings, err := app.models.Rating.GetForUserMovies(gr.user.ID, ids)
+		if err != nil {
+			return nil, app.graphqlInternalError(gr, err)
+		}
+
+		byMovie := make(map[int64]*data.Rating, len(ratings))
+		for _, rating := range ratings {
+			byMovie[rating.MovieID] = rating
+		}
+		return byMovie, nil
+	})
+
+	return gr
+}
+
+func graphqlRequestFrom(ctx context.Context) *graphqlRequest {
+	return ctx.Value(graphqlContextKey).(*graphqlRequest)
+}
+
+// graphqlInternalError logs the error and hides it from the client like internalServerErrorResponse
+func (app *application) graphqlInternalError(gr *graphqlRequest, err error) error {
+	app.logError(gr.req, err)
+	return &graphqlError{
+		message:    "The server encountered a problem and cannot process incoming request",
+		extensions: map[string]interface{}{"code": "INTERNAL_SERVER_ERROR"},
+	}
+}
+
+// graphqlRequirePermission is requirePermission for the mutations, /v1/graphql itself is
+// public so every mutation checks its caller
+func (app *application) graphqlRequirePermission(gr *graphqlRequest, code string) error {
+	if gr.user.IsAnonymous() {
+		return &graphqlError{
+			message:    "You must be authenticated to access this resource",
+			extensions: map[string]interface{}{"code": "UNAUTHENTICATED"},
+		}
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(gr.user.ID)
+	if err != nil {
+		return app.graphqlInternalError(gr, err)
+	}
+
+	if !permissions.Include(code) {
+		return &graphqlError{
+			message:    "Your user account doesn't have the necessary permissions to access this resource",
+			extensions: map[string]interface{}{"code": "FORBIDDEN"},
+		}
+	}
+
+	return nil
+}
+
+// graphqlID parses an ID argument, which GraphQL clients send as a string
+func graphqlID(value interface{}) (int64, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+func (app *application) graphqlSchema() (graphql.Schema, error) {
+	paginationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PaginationMetadata",
+		Fields: graphql.Fields{
+			"pageSize":     &graphql.Field{Type: graphql.Int},
+			"firstPage":    &graphql.Field{Type: graphql.Int},
+			"currentPage":  &graphql.Field{Type: graphql.Int},
+			"lastPage":     &graphql.Field{Type: graphql.Int},
+			"totalRecords": &graphql.Field{Type: graphql.Int},
+			"nextCursor":   &graphql.Field{Type: graphql.String},
+			"prevCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	personType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Person",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	creditType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Credit",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"role":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"characterName": &graphql.Field{Type: graphql.String},
+			"billingOrder":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"person":        &graphql.Field{Type: graphql.NewNonNull(personType)},
+		},
+	})
+
+	ratingType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Rating",
+		Fields: graphql.Fields{
+			"score":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	movieType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Movie",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"title":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"originalTitle": &graphql.Field{Type: graphql.String},
+			"locale":        &graphql.Field{Type: graphql.String},
+			"year":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"runtime":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"genres":        &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"director":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"actors":        &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"plot":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"posterUrl":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"ratingAverage": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"ratingCount":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"firstReleaseDate": &graphql.Field{
+				Type:        graphql.String,
+				Description: "The earliest release anywhere, formatted as YYYY-MM-DD",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					date := p.Source.(*data.Movie).FirstReleaseDate
+					if date == nil {
+						return nil, nil
+					}
+					return date.Format(time.DateOnly), nil
+				},
+			},
+			"version": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Passed back to updateMovie to detect edit conflicts",
+			},
+			"credits": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(creditType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return graphqlRequestFrom(p.Context).credits.load(p.Source.(*data.Movie).ID), nil
+				},
+			},
+			"myRating": &graphql.Field{
+				Type:        ratingType,
+				Description: "The rating of the authenticated user, null for anonymous callers",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := graphqlRequestFrom(p.Context)
+					if gr.user.IsAnonymous() {
+						return nil, nil
+					}
+					return gr.ratings.load(p.Source.(*data.Movie).ID), nil
+				},
+			},
+		},
+	})
+
+	ratingType.AddFieldConfig("movie", &graphql.Field{
+		Type: movieType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return graphqlRequestFrom(p.Context).movies.load(p.Source.(*data.Rating).MovieID), nil
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"activated": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"ratings": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(ratingType))),
+				Description: "The ratings of the user, the most recent first",
+				Args: graphql.FieldConfigArgument{
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+				},
+				Resolve: app.resolveUserRatings,
+			},
+		},
+	})
+
+	moviePageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MoviePage",
+		Fields: graphql.Fields{
+			"items":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(movieType)))},
+			"pagination": &graphql.Field{Type: graphql.NewNonNull(paginationType)},
+		},
+	})
+
+	movieInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "MovieInput",
+		Description: "Fields left out keep their value on update",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"year":      &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"runtime":   &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"genres":    &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"director":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"actors":    &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"plot":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"posterUrl": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movies": &graphql.Field{
+				Type:        graphql.NewNonNull(moviePageType),
+				Description: "Lists the movies with the filters and pagination of GET /v1/movies",
+				Args: graphql.FieldConfigArgument{
+					"title":         &graphql.ArgumentConfig{Type: graphql.String},
+					"q":             &graphql.ArgumentConfig{Type: graphql.String},
+					"genresAll":     &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"genresAny":     &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"yearMin":       &graphql.ArgumentConfig{Type: graphql.Int},
+					"yearMax":       &graphql.ArgumentConfig{Type: graphql.Int},
+					"runtimeMin":    &graphql.ArgumentConfig{Type: graphql.Int},
+					"runtimeMax":    &graphql.ArgumentConfig{Type: graphql.Int},
+					"director":      &graphql.ArgumentConfig{Type: graphql.String},
+					"actor":         &graphql.ArgumentConfig{Type: graphql.String},
+					"createdAfter":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"createdBefore": &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"region":        &graphql.ArgumentConfig{Type: graphql.String},
+					"certification": &graphql.ArgumentConfig{Type: graphql.String},
+					"minVotes":      &graphql.ArgumentConfig{Type: graphql.Int},
+					"page":          &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"pageSize":      &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+					"cursor":        &graphql.ArgumentConfig{Type: graphql.String},
+					"includeTotal":  &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+					"sort":          &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "id"},
+				},
+				Resolve: app.resolveMovies,
+			},
+			"movie": &graphql.Field{
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, ok := graphqlID(p.Args["id"])
+					if !ok {
+						return nil, nil
+					}
+					return graphqlRequestFrom(p.Context).movies.load(id), nil
+				},
+			},
+			"me": &graphql.Field{
+				Type:        userType,
+				Description: "The authenticated user, null for anonymous callers",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					gr := graphqlRequestFrom(p.Context)
+					if gr.user.IsAnonymous() {
+						return nil, nil
+					}
+					return gr.user, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": &graphql.Field{
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(movieInputType)},
+					// force skips the duplicate check like ?force=true on POST /v1/movies
+					"force": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: app.resolveCreateMovie,
+			},
+			"updateMovie": &graphql.Field{
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"version": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"input":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(movieInputType)},
+				},
+				Resolve: app.resolveUpdateMovie,
+			},
+			"deleteMovie": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: app.resolveDeleteMovie,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+func (app *application) resolveMovies(p graphql.ResolveParams) (interface{}, error) {
+	gr := graphqlRequestFrom(p.Context)
+
+	var movieQuery data.MovieQuery
+	var filters data.Filters
+
+	movieQuery.Title, _ = p.Args["title"].(string)
+	movieQuery.Search, _ = p.Args["q"].(string)
+	movieQuery.SimilarityThreshold = app.config.search.similarityThreshold
+	movieQuery.GenresAll = graphqlStrings(p.Args["genresAll"])
+	movieQuery.GenresAny = graphqlStrings(p.Args["genresAny"])
+	movieQuery.YearMin, _ = p.Args["yearMin"].(int)
+	movieQuery.YearMax, _ = p.Args["yearMax"].(int)
+	movieQuery.RuntimeMin, _ = p.Args["runtimeMin"].(int)
+	movieQuery.RuntimeMax, _ = p.Args["runtimeMax"].(int)
+	movieQuery.Director, _ = p.Args["director"].(string)
+	movieQuery.Actor, _ = p.Args["actor"].(string)
+	movieQuery.CreatedAfter = graphqlTime(p.Args["createdAfter"])
+	movieQuery.CreatedBefore = graphqlTime(p.Args["createdBefore"])
+	movieQuery.Region, _ = p.Args["region"].(string)
+	movieQuery.Certification, _ = p.Args["certification"].(string)
+	movieQuery.MinVotes, _ = p.Args["minVotes"].(int)
+	movieQuery.Locales = gr.locales
+
+	filters.Page, _ = p.Args["page"].(int)
+	filters.PageSize, _ = p.Args["pageSize"].(int)
+	filters.Cursor, _ = p.Args["cursor"].(string)
+	filters.IncludeTotal, _ = p.Args["includeTotal"].(bool)
+	filters.Sort, _ = p.Args["sort"].(string)
+	filters.SortSafeList = movieSortSafeList
+
+	validate := validator.New()
+
+	data.ValidateMovieQuery(validate, &movieQuery)
+	validate.Check(filters.Sort != "relevance" || movieQuery.Search != "", "sort", "Relevance Sort Requires q")
+
+	if data.ValidateFilters(validate, &filters); !validate.Valid() {
+		return nil, graphqlValidationError(validate.Errors)
+	}
+
+	movies, pagination, _, err := app.models.Movie.GetAll(movieQuery, filters)
+	if err != nil {
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	err = app.models.Translations.Localize(gr.locales, movies...)
+	if err != nil {
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	return map[string]interface{}{
+		"items":      movies,
+		"pagination": pagination,
+	}, nil
+}
+
+func (app *application) resolveUserRatings(p graphql.ResolveParams) (interface{}, error) {
+	gr := graphqlRequestFrom(p.Context)
+
+	limit, _ := p.Args["limit"].(int)
+
+	validate := validator.New()
+	validate.Check(limit > 0, "limit", "limit is invalid")
+	validate.Check(limit <= 100, "limit", "limit exceed maximum")
+
+	if !validate.Valid() {
+		return nil, graphqlValidationError(validate.Errors)
+	}
+
+	ratings, err := app.models.Rating.GetForUser(p.Source.(*data.User).ID)
+	if err != nil {
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	if len(ratings) > limit {
+		ratings = ratings[:limit]
+	}
+
+	return ratings, nil
+}
+
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (interface{}, error) {
+	gr := graphqlRequestFrom(p.Context)
+
+	if err := app.graphqlRequirePermission(gr, data.PermissionWriteMovies); err != nil {
+		return nil, err
+	}
+
+	movie := &data.Movie{}
+	applyMovieInput(movie, p.Args["input"].(map[string]interface{}))
+
+	taxonomy, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	validate := validator.New()
+
+	if data.ValidateMovie(validate, movie, taxonomy); !validate.Valid() {
+		return nil, graphqlValidationError(validate.Errors)
+	}
+
+	force, _ := p.Args["force"].(bool)
+
+	if !force && app.config.search.duplicateThreshold > 0 {
+		candidates, err := app.models.Movie.FindDuplicates(movie, app.config.search.duplicateThreshold)
+		if err != nil {
+			return nil, app.graphqlInternalError(gr, err)
+		}
+
+		if len(candidates) > 0 {
+			return nil, &graphqlError{
+				message:    "The movie looks like one which already exists, resend with force: true to create it anyway",
+				extensions: map[string]interface{}{"code": "DUPLICATE", "candidates": candidates},
+			}
+		}
+	}
+
+	err = app.models.Movie.Insert(movie)
+	if err != nil {
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	return movie, nil
+}
+
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (interface{}, error) {
+	gr := graphqlRequestFrom(p.Context)
+
+	if err := app.graphqlRequirePermission(gr, data.PermissionWriteMovies); err != nil {
+		return nil, err
+	}
+
+	id, ok := graphqlID(p.Args["id"])
+	if !ok {
+		return nil, graphqlNotFoundError()
+	}
+
+	movie, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return nil, graphqlNotFoundError()
+		}
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	// The version the client read wins, so the update fails when the movie changed since
+	if version := int32(p.Args["version"].(int)); version != movie.Version {
+		return nil, graphqlEditConflictError()
+	}
+
+	applyMovieInput(movie, p.Args["input"].(map[string]interface{}))
+
+	taxonomy, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	validate := validator.New()
+
+	if data.ValidateMovie(validate, movie, taxonomy); !validate.Valid() {
+		return nil, graphqlValidationError(validate.Errors)
+	}
+
+	err = app.models.Movie.Update(movie)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return nil, graphqlEditConflictError()
+		}
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	return movie, nil
+}
+
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (interface{}, error) {
+	gr := graphqlRequestFrom(p.Context)
+
+	if err := app.graphqlRequirePermission(gr, data.PermissionWriteMovies); err != nil {
+		return nil, err
+	}
+
+	id, ok := graphqlID(p.Args["id"])
+	if !ok {
+		return nil, graphqlNotFoundError()
+	}
+
+	err := app.models.Movie.Delete(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return nil, graphqlNotFoundError()
+		}
+		return nil, app.graphqlInternalError(gr, err)
+	}
+
+	return id, nil
+}
+
+// applyMovieInput copies the fields given in a MovieInput onto the movie
+func applyMovieInput(movie *data.Movie, input map[string]interface{}) {
+	if title, ok := input["title"].(string); ok {
+		movie.Title = title
+	}
+
+	if year, ok := input["year"].(int); ok {
+		movie.Year = int32(year)
+	}
+
+	if runtime, ok := input["runtime"].(int); ok {
+		movie.Runtime = int32(runtime)
+	}
+
+	if genres, ok := input["genres"]; ok && genres != nil {
+		movie.Genres = graphqlStrings(genres)
+	}
+
+	if director, ok := input["director"].(string); ok {
+		movie.Director = director
+	}
+
+	if actors, ok := input["actors"]; ok && actors != nil {
+		movie.Actors = graphqlStrings(actors)
+	}
+
+	if plot, ok := input["plot"].(string); ok {
+		movie.Plot = plot
+	}
+
+	if posterURL, ok := input["posterUrl"].(string); ok {
+		movie.PosterURL = posterURL
+	}
+}
+
+// graphqlStrings converts a list argument, which arrives as []interface{}
+func graphqlStrings(value interface{}) []string {
+	list, _ := value.([]interface{})
+
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func graphqlTime(value interface{}) time.Time {
+	switch t := value.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+	}
+	return time.Time{}
+}
+
+// graphqlHandler serves POST /v1/graphql. The schema is built once, every request
+// gets its own loaders so nothing is cached between requests.
+func (app *application) graphqlHandler() http.HandlerFunc {
+	schema, err := app.graphqlSchema()
+	if err != nil {
+		panic(err)
+	}
+
+	return func(res http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+
+		err := app.readJSON(res, req, &body)
+		if err != nil {
+			app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		validate := validator.New()
+
+		validate.Check(body.Query != "", "query", "query must be provided")
+		validate.Check(len(body.Query) <= 20000, "query", "query max length is 20000 characters")
+
+		if !validate.Valid() {
+			app.failedValidationResponse(res, req, validate.Errors)
+			return
+		}
+
+		result := app.executeGraphql(req, &schema, body.Query, body.OperationName, body.Variables)
+
+		err = app.writeJSON(res, http.StatusOK, result, nil)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+	}
+}
+
+// executeGraphql parses and validates the query, checks it against the depth, complexity
+// and introspection limits and only then executes it
+func (app *application) executeGraphql(req *http.Request, schema *graphql.Schema, query, operationName string, variables map[string]interface{}) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(schema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	limits := &graphqlLimits{
+		maxDepth:      app.config.graphql.maxDepth,
+		maxComplexity: app.config.graphql.maxComplexity,
+		introspection: app.config.graphql.introspection,
+		variables:     variables,
+	}
+
+	err = limits.check(document, operationName)
+	if err != nil {
+		rejected := gqlerrors.NewFormattedError(err.Error())
+		rejected.Extensions = map[string]interface{}{"code": "QUERY_REJECTED"}
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{rejected}}
+	}
+
+	ctx := context.WithValue(req.Context(), graphqlContextKey, app.newGraphqlRequest(req))
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        *schema,
+		AST:           document,
+		OperationName: operationName,
+		Args:          variables,
+		Context:       ctx,
+	})
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"strconv"
+)
+
+// graphqlListSizes is the number of items a list field is assumed to return when the
+// query doesn't say, the limit or pageSize argument is used when it is given
+var graphqlListSizes = map[string]int{
+	"movies":  10,
+	"credits": 20,
+	"ratings": 20,
+}
+
+// graphqlLimits walks the selected operation of a query before it is executed, with
+// the fragments inlined, to reject queries which are too deep, too expensive or which
+// introspect the schema while introspection is disabled
+type graphqlLimits struct {
+	maxDepth      int
+	maxComplexity int
+	introspection bool
+	variables     map[string]interface{}
+	fragments     map[string]*ast.FragmentDefinition
+}
+
+func (l *graphqlLimits) check(document *ast.Document, operationName string) error {
+	l.fragments = make(map[string]*ast.FragmentDefinition)
+
+	var operations []*ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			l.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operations = append(operations, definition)
+			}
+		}
+	}
+
+	// Anything else is reported by the executor
+	if len(operations) != 1 {
+		return nil
+	}
+
+	complexity, err := l.walk(operations[0].SelectionSet, 1, 1, map[string]bool{})
+	if err != nil {
+		return err
+	}
+
+	if complexity > l.maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", complexity, l.maxComplexity)
+	}
+
+	return nil
+}
+
+// walk returns the complexity of a selection set, every field costs one for each item of
+// the lists it is nested in. visited guards against fragments which spread themselves.
+func (l *graphqlLimits) walk(set *ast.SelectionSet, depth, multiplier int, visited map[string]bool) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+
+	if depth > l.maxDepth {
+		return 0, fmt.Errorf("query depth exceeds the maximum of %d", l.maxDepth)
+	}
+
+	complexity := 0
+
+	for _, selection := range set.Selections {
+		var cost int
+		var err error
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			if !l.introspection && (name == "__schema" || name == "__type") {
+				return 0, fmt.Errorf("introspection is disabled")
+			}
+
+			size := 1
+			if selection.SelectionSet != nil {
+				size = l.listSize(selection)
+			}
+
+			cost, err = l.walk(selection.SelectionSet, depth+1, multiplier*size, visited)
+			cost += multiplier
+
+		case *ast.InlineFragment:
+			cost, err = l.walk(selection.SelectionSet, depth, multiplier, visited)
+
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := l.fragments[name]
+			if !ok || visited[name] {
+				continue
+			}
+
+			visited[name] = true
+			cost, err = l.walk(fragment.SelectionSet, depth, multiplier, visited)
+			delete(visited, name)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		complexity += cost
+		if complexity > l.maxComplexity {
+			return complexity, nil
+		}
+	}
+
+	return complexity, nil
+}
+
+// listSize is the number of items a field is expected to return, one for non-list fields
+func (l *graphqlLimits) listSize(field *ast.Field) int {
+	size, ok := graphqlListSizes[field.Name.Value]
+	if !ok {
+		return 1
+	}
+
+	for _, argument := range field.Arguments {
+		switch argument.Name.Value {
+		case "limit", "pageSize":
+			if n, ok := l.intValue(argument.Value); ok && n > 0 {
+				size = n
+			}
+		}
+	}
+
+	return size
+}
+
+func (l *graphqlLimits) intValue(value ast.Value) (int, bool) {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(value.Value)
+		return n, err == nil
+	case *ast.Variable:
+		// Variables are decoded from JSON, so numbers are float64
+		if n, ok := l.variables[value.Name.Value].(float64); ok {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
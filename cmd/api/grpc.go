@@ -0,0 +1,413 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/moviepb"
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// movieServer implements the gRPC MovieService on top of the same models and
+// validation as the REST handlers
+type movieServer struct {
+	moviepb.UnimplementedMovieServiceServer
+	app *application
+}
+
+// grpcServer builds the gRPC server with the movie service, the health service
+// and, when enabled, server reflection
+func (app *application) grpcServer() (*grpc.Server, *health.Server) {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(app.grpcRecoverPanic, app.grpcAuthenticate))
+
+	moviepb.RegisterMovieServiceServer(srv, &movieServer{app: app})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(moviepb.MovieService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	if app.config.grpc.reflection {
+		reflection.Register(srv)
+	}
+
+	return srv, healthServer
+}
+
+// grpcRecoverPanic turns a panic in a handler into an INTERNAL error like recoverPanic does for HTTP
+func (app *application) grpcRecoverPanic(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = app.grpcInternalError(ctx, fmt.Errorf("%s", p))
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// grpcMethodPermissions maps the methods which change movies to the permission they need
+// like their REST routes, every other method is public
+var grpcMethodPermissions = map[string]string{
+	moviepb.MovieService_CreateMovie_FullMethodName: data.PermissionWriteMovies,
+	moviepb.MovieService_UpdateMovie_FullMethodName: data.PermissionWriteMovies,
+	moviepb.MovieService_DeleteMovie_FullMethodName: data.PermissionWriteMovies,
+}
+
+// grpcAuthenticate is authenticate and requirePermission for gRPC. The bearer token comes
+// from the authorization metadata and its user is added to the context.
+func (app *application) grpcAuthenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	user := data.AnonymousUser
+
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+
+		v := validator.New()
+		if data.ValidateTokenPlainText(v, token); !ok || !v.Valid() {
+			return nil, status.Error(codes.Unauthenticated, "Invalid or missing authentication token")
+		}
+
+		var err error
+		user, err = app.models.User.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRecordsFound) {
+				return nil, status.Error(codes.Unauthenticated, "Invalid or missing authentication token")
+			}
+			return nil, app.grpcInternalError(ctx, err)
+		}
+	}
+
+	if code, ok := grpcMethodPermissions[info.FullMethod]; ok {
+		if user.IsAnonymous() {
+			return nil, status.Error(codes.Unauthenticated, "You must be authenticated to access this resource")
+		}
+
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			return nil, app.grpcInternalError(ctx, err)
+		}
+
+		if !permissions.Include(code) {
+			return nil, status.Error(codes.PermissionDenied, "Your user account doesn't have the necessary permissions to access this resource")
+		}
+	}
+
+	return handler(context.WithValue(ctx, userContextKey, user), req)
+}
+
+// grpcInternalError logs the error and hides it from the client like internalServerErrorResponse
+func (app *application) grpcInternalError(ctx context.Context, err error) error {
+	method, _ := grpc.Method(ctx)
+	app.logger.PrintError(err, map[string]string{"grpc_method": method})
+	return status.Error(codes.Internal, "The server encountered a problem and cannot process incoming request")
+}
+
+// grpcValidationError carries the validator errors as field violations, sorted by field
+// so the details are the same for the same request
+func grpcValidationError(errors map[string]string) error {
+	fields := make([]string, 0, len(errors))
+	for field := range errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: errors[field],
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, "Failed validation").WithDetails(badRequest)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "Failed validation")
+	}
+	return st.Err()
+}
+
+func grpcNotFoundError() error {
+	return status.Error(codes.NotFound, "The requested resource could not be found")
+}
+
+func grpcEditConflictError() error {
+	return status.Error(codes.Aborted, "Unable to update the record due to an edit conflict, please try again")
+}
+
+func movieToProto(movie *data.Movie) *moviepb.Movie {
+	pb := &moviepb.Movie{
+		Id:            movie.ID,
+		Title:         movie.Title,
+		Year:          movie.Year,
+		Runtime:       movie.Runtime,
+		Genres:        movie.Genres,
+		Director:      movie.Director,
+		Actors:        movie.Actors,
+		Plot:          movie.Plot,
+		PosterUrl:     movie.PosterURL,
+		RatingAverage: movie.RatingAverage,
+		RatingCount:   movie.RatingCount,
+		Version:       movie.Version,
+	}
+
+	if movie.FirstReleaseDate != nil {
+		pb.FirstReleaseDate = movie.FirstReleaseDate.Format(time.DateOnly)
+	}
+
+	return pb
+}
+
+// movieWritableFields are the paths an update mask may list
+var movieWritableFields = []string{"title", "year", "runtime", "genres", "director", "actors", "plot", "poster_url"}
+
+// applyMovieProto copies the fields in paths from the message onto the movie
+func applyMovieProto(movie *data.Movie, pb *moviepb.Movie, paths []string) {
+	for _, path := range paths {
+		switch path {
+		case "title":
+			movie.Title = pb.GetTitle()
+		case "year":
+			movie.Year = pb.GetYear()
+		case "runtime":
+			movie.Runtime = pb.GetRuntime()
+		case "genres":
+			movie.Genres = pb.GetGenres()
+		case "director":
+			movie.Director = pb.GetDirector()
+		case "actors":
+			movie.Actors = pb.GetActors()
+		case "plot":
+			movie.Plot = pb.GetPlot()
+		case "poster_url":
+			movie.PosterURL = pb.GetPosterUrl()
+		}
+	}
+}
+
+func (s *movieServer) GetMovie(ctx context.Context, req *moviepb.GetMovieRequest) (*moviepb.Movie, error) {
+	if req.GetId() < 1 {
+		return nil, grpcNotFoundError()
+	}
+
+	movie, err := s.app.models.Movie.Get(req.GetId())
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return nil, grpcNotFoundError()
+		}
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	return movieToProto(movie), nil
+}
+
+func (s *movieServer) ListMovies(ctx context.Context, req *moviepb.ListMoviesRequest) (*moviepb.ListMoviesResponse, error) {
+	movieQuery := data.MovieQuery{
+		Title:               req.GetTitle(),
+		Search:              req.GetQ(),
+		GenresAll:           req.GetGenresAll(),
+		GenresAny:           req.GetGenresAny(),
+		YearMin:             int(req.GetYearMin()),
+		YearMax:             int(req.GetYearMax()),
+		RuntimeMin:          int(req.GetRuntimeMin()),
+		RuntimeMax:          int(req.GetRuntimeMax()),
+		Director:            req.GetDirector(),
+		Actor:               req.GetActor(),
+		Region:              strings.ToUpper(req.GetRegion()),
+		Certification:       req.GetCertification(),
+		MinVotes:            int(req.GetMinVotes()),
+		SimilarityThreshold: s.app.config.search.similarityThreshold,
+	}
+
+	if req.GetCreatedAfter() != nil {
+		movieQuery.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+
+	if req.GetCreatedBefore() != nil {
+		movieQuery.CreatedBefore = req.GetCreatedBefore().AsTime()
+	}
+
+	filters := data.Filters{
+		Page:         int(req.GetPage()),
+		PageSize:     int(req.GetPageSize()),
+		Cursor:       req.GetCursor(),
+		IncludeTotal: !req.GetSkipTotal(),
+		Sort:         req.GetSort(),
+		SortSafeList: movieSortSafeList,
+	}
+
+	// Unset fields read as zero, so they get the defaults of the REST listing
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+
+	if filters.PageSize == 0 {
+		filters.PageSize = 10
+	}
+
+	if filters.Sort == "" {
+		filters.Sort = "id"
+	}
+
+	validate := validator.New()
+
+	data.ValidateMovieQuery(validate, &movieQuery)
+	validate.Check(filters.Sort != "relevance" || movieQuery.Search != "", "sort", "Relevance Sort Requires q")
+
+	if data.ValidateFilters(validate, &filters); !validate.Valid() {
+		return nil, grpcValidationError(validate.Errors)
+	}
+
+	movies, pagination, _, err := s.app.models.Movie.GetAll(movieQuery, filters)
+	if err != nil {
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	res := &moviepb.ListMoviesResponse{
+		Movies: make([]*moviepb.Movie, len(movies)),
+		Pagination: &moviepb.Pagination{
+			PageSize:     int32(pagination.PageSize),
+			FirstPage:    int32(pagination.FirstPage),
+			CurrentPage:  int32(pagination.CurrentPage),
+			LastPage:     int32(pagination.LastPage),
+			TotalRecords: int32(pagination.TotalRecords),
+			NextCursor:   pagination.NextCursor,
+			PrevCursor:   pagination.PrevCursor,
+		},
+	}
+
+	for i, movie := range movies {
+		res.Movies[i] = movieToProto(movie)
+	}
+
+	return res, nil
+}
+
+func (s *movieServer) CreateMovie(ctx context.Context, req *moviepb.CreateMovieRequest) (*moviepb.Movie, error) {
+	movie := &data.Movie{}
+	applyMovieProto(movie, req.GetMovie(), movieWritableFields)
+
+	taxonomy, err := s.app.models.Genres.Taxonomy()
+	if err != nil {
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	validate := validator.New()
+
+	if data.ValidateMovie(validate, movie, taxonomy); !validate.Valid() {
+		return nil, grpcValidationError(validate.Errors)
+	}
+
+	if !req.GetForce() && s.app.config.search.duplicateThreshold > 0 {
+		candidates, err := s.app.models.Movie.FindDuplicates(movie, s.app.config.search.duplicateThreshold)
+		if err != nil {
+			return nil, s.app.grpcInternalError(ctx, err)
+		}
+
+		if len(candidates) > 0 {
+			ids := make([]string, len(candidates))
+			for i, candidate := range candidates {
+				ids[i] = strconv.FormatInt(candidate.ID, 10)
+			}
+
+			st, err := status.New(codes.AlreadyExists, "The movie looks like one which already exists, resend with force to create it anyway").
+				WithDetails(&errdetails.ErrorInfo{
+					Reason:   "DUPLICATE_MOVIE",
+					Domain:   "api.go-rifqio.my.id",
+					Metadata: map[string]string{"candidates": strings.Join(ids, ",")},
+				})
+			if err != nil {
+				return nil, s.app.grpcInternalError(ctx, err)
+			}
+			return nil, st.Err()
+		}
+	}
+
+	err = s.app.models.Movie.Insert(movie)
+	if err != nil {
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	return movieToProto(movie), nil
+}
+
+func (s *movieServer) UpdateMovie(ctx context.Context, req *moviepb.UpdateMovieRequest) (*moviepb.Movie, error) {
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = movieWritableFields
+	}
+
+	validate := validator.New()
+
+	for _, path := range paths {
+		validate.Check(validator.In(path, movieWritableFields...), "update_mask", fmt.Sprintf("%s is not a writable field", path))
+	}
+
+	if !validate.Valid() {
+		return nil, grpcValidationError(validate.Errors)
+	}
+
+	if req.GetId() < 1 {
+		return nil, grpcNotFoundError()
+	}
+
+	movie, err := s.app.models.Movie.Get(req.GetId())
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return nil, grpcNotFoundError()
+		}
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	// The version the client read wins, so the update fails when the movie changed since
+	if req.GetVersion() != movie.Version {
+		return nil, grpcEditConflictError()
+	}
+
+	applyMovieProto(movie, req.GetMovie(), paths)
+
+	taxonomy, err := s.app.models.Genres.Taxonomy()
+	if err != nil {
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	if data.ValidateMovie(validate, movie, taxonomy); !validate.Valid() {
+		return nil, grpcValidationError(validate.Errors)
+	}
+
+	err = s.app.models.Movie.Update(movie)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return nil, grpcEditConflictError()
+		}
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	return movieToProto(movie), nil
+}
+
+func (s *movieServer) DeleteMovie(ctx context.Context, req *moviepb.DeleteMovieRequest) (*emptypb.Empty, error) {
+	if req.GetId() < 1 {
+		return nil, grpcNotFoundError()
+	}
+
+	err := s.app.models.Movie.Delete(req.GetId())
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return nil, grpcNotFoundError()
+		}
+		return nil, s.app.grpcInternalError(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"api.go-rifqio.my.id/internal/data"
 	"api.go-rifqio.my.id/internal/validator"
 	"encoding/json"
 	"errors"
@@ -9,8 +10,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type envelope map[string]interface{}
@@ -43,6 +46,43 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return val
 }
 
+// readBool read string value from query string and convert to boolean before returning
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	queryKey := qs.Get(key)
+
+	if queryKey == "" {
+		return defaultValue
+	}
+
+	val, err := strconv.ParseBool(queryKey)
+	if err != nil {
+		v.AddError(key, "key must be a boolean value")
+		return defaultValue
+	}
+
+	return val
+}
+
+// readTime read string value from query string and parse it as RFC 3339 timestamp
+// or a plain date (2006-01-02) before returning
+func (app *application) readTime(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	queryKey := qs.Get(key)
+
+	if queryKey == "" {
+		return defaultValue
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		val, err := time.Parse(layout, queryKey)
+		if err == nil {
+			return val
+		}
+	}
+
+	v.AddError(key, "key must be a RFC 3339 timestamp or a date")
+	return defaultValue
+}
+
 // readCSV will read comma separated value, example on this route
 // /v1/movies?title=godfather&genres=crime,drama
 func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
@@ -53,11 +93,66 @@ func (app *application) readCSV(qs url.Values, key string, defaultValue []string
 	return strings.Split(csv, ",")
 }
 
+// readLocales negotiates the locales a response should be translated to, most preferred first.
+// A ?lang= list wins over the Accept-Language header, invalid tags and the * range are skipped.
+func (app *application) readLocales(req *http.Request) []string {
+	var tags []string
+
+	if lang := req.URL.Query().Get("lang"); lang != "" {
+		tags = strings.Split(lang, ",")
+	} else {
+		type weighted struct {
+			tag    string
+			weight float64
+		}
+
+		var ranges []weighted
+		for _, value := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+			tag, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+
+			weight := 1.0
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				parsed, err := strconv.ParseFloat(q, 64)
+				if err != nil {
+					continue
+				}
+				weight = parsed
+			}
+
+			if weight > 0 {
+				ranges = append(ranges, weighted{tag: tag, weight: weight})
+			}
+		}
+
+		sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].weight > ranges[j].weight })
+
+		for _, r := range ranges {
+			tags = append(tags, r.tag)
+		}
+	}
+
+	var locales []string
+	for _, tag := range tags {
+		locale, ok := data.CanonicalLocale(strings.TrimSpace(tag))
+		if ok && !validator.In(locale, locales...) {
+			locales = append(locales, locale)
+		}
+	}
+
+	return locales
+}
+
 func (app *application) readIDParam(req *http.Request) (int64, error) {
+	return app.readNamedIDParam(req, "id")
+}
+
+// readNamedIDParam read a positive id from the route parameter with the given name,
+// for routes which hold more than one id like /v1/collections/:id/movies/:movie_id
+func (app *application) readNamedIDParam(req *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(req.Context())
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, err
+		return 0, errors.New("invalid id parameter")
 	}
 	return id, nil
 }
@@ -125,6 +220,7 @@ func (app *application) background(fn func()) {
 				app.logger.PrintError(fmt.Errorf("%s", err), nil)
 			}
 		}()
+
+		fn()
 	}()
-	fn()
 }
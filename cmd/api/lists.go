@@ -0,0 +1,206 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The watchlist and favourites share their handlers, every handler
+// is created for the list it serves when the routes are registered
+
+func (app *application) showListHandler(list string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		var filters data.Filters
+
+		validate := validator.New()
+		qs := req.URL.Query()
+
+		filters.Page = app.readInt(qs, "page", 1, validate)
+		filters.PageSize = app.readInt(qs, "page_size", 10, validate)
+		filters.Cursor = app.readString(qs, "cursor", "")
+		filters.IncludeTotal = app.readBool(qs, "include_total", true, validate)
+
+		filters.Sort = app.readString(qs, "sort", "-added_at")
+		filters.SortSafeList = []string{
+			"added_at", "title", "year", "runtime", "rating",
+			"-added_at", "-title", "-year", "-runtime", "-rating",
+		}
+
+		if data.ValidateFilters(validate, &filters); !validate.Valid() {
+			app.failedValidationResponse(res, req, validate.Errors)
+			return
+		}
+
+		entries, paginationMetadata, err := app.models.Lists.GetAll(app.contextGetUser(req).ID, list, filters)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		response := data.NewResponse()
+		response.Result = entries
+		response.Message = "Movies Fetched Successfully"
+		response.Pagination = &paginationMetadata
+
+		err = app.writeJSON(res, 200, response, nil)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+	}
+}
+
+func (app *application) addToListHandler(list string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		movieID, err := app.readIDParam(req)
+		if err != nil {
+			app.notFoundResponse(res, req)
+			return
+		}
+
+		type AddToListDTO struct {
+			Watched bool `json:"watched"`
+		}
+
+		body := new(AddToListDTO)
+
+		// The body is optional, a movie is added as not watched by default
+		if req.ContentLength != 0 {
+			err = app.readJSON(res, req, &body)
+			if err != nil {
+				app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+
+		entry, err := app.models.Lists.Add(app.contextGetUser(req).ID, list, movieID, body.Watched && list == data.ListWatchlist)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRecordsFound) {
+				app.notFoundResponse(res, req)
+				return
+			}
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		response := data.NewResponse()
+		response.Result = entry
+		response.Message = fmt.Sprintf("Movie Added To %s Successfully", list)
+
+		err = app.writeJSON(res, 200, response, nil)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+	}
+}
+
+func (app *application) updateListEntryHandler(list string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		movieID, err := app.readIDParam(req)
+		if err != nil {
+			app.notFoundResponse(res, req)
+			return
+		}
+
+		type UpdateListEntryDTO struct {
+			Watched *bool `json:"watched"`
+		}
+
+		body := new(UpdateListEntryDTO)
+
+		err = app.readJSON(res, req, &body)
+		if err != nil {
+			app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		validate := validator.New()
+		if validate.Check(body.Watched != nil, "watched", "watched must be provided"); !validate.Valid() {
+			app.failedValidationResponse(res, req, validate.Errors)
+			return
+		}
+
+		entry, err := app.models.Lists.SetWatched(app.contextGetUser(req).ID, list, movieID, *body.Watched)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRecordsFound) {
+				app.notFoundResponse(res, req)
+				return
+			}
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		response := data.NewResponse()
+		response.Result = entry
+		response.Message = fmt.Sprintf("Movie On %s Updated Successfully", list)
+
+		err = app.writeJSON(res, 200, response, nil)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+	}
+}
+
+func (app *application) removeFromListHandler(list string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		movieID, err := app.readIDParam(req)
+		if err != nil {
+			app.notFoundResponse(res, req)
+			return
+		}
+
+		err = app.models.Lists.Remove(app.contextGetUser(req).ID, list, movieID)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRecordsFound) {
+				app.notFoundResponse(res, req)
+				return
+			}
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		response := data.NewResponse()
+		response.Result = envelope{"movie_id": movieID}
+		response.Message = fmt.Sprintf("Movie Removed From %s Successfully", list)
+
+		err = app.writeJSON(res, 200, response, nil)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+	}
+}
+
+// attachListStatus fills the list status of the movies for an authenticated caller
+// with one lookup for the whole page, anonymous callers are left untouched
+func (app *application) attachListStatus(req *http.Request, movies ...*data.Movie) error {
+	user := app.contextGetUser(req)
+	if user.IsAnonymous() || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	statuses, err := app.models.Lists.StatusForMovies(user.ID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if status, ok := statuses[movie.ID]; ok {
+			movie.Lists = status
+		} else {
+			movie.Lists = &data.MovieListStatus{}
+		}
+	}
+
+	return nil
+}
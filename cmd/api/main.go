@@ -3,9 +3,12 @@ package main
 import (
 	"api.go-rifqio.my.id/internal/data"
 	newLogger "api.go-rifqio.my.id/internal/logger"
+	"api.go-rifqio.my.id/internal/plotindex"
 	"api.go-rifqio.my.id/internal/smtp"
+	"api.go-rifqio.my.id/internal/storage"
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -34,6 +37,65 @@ type config struct {
 		enabled bool
 	}
 
+	search struct {
+		similarityThreshold   float64
+		autocompleteThreshold float64
+		autocompleteLimit     int
+		duplicateThreshold    float64
+	}
+
+	storage struct {
+		dir     string
+		baseURL string
+	}
+
+	posters struct {
+		maxBytes  int64
+		maxPixels int
+	}
+
+	similar struct {
+		genres     float64
+		actors     float64
+		director   float64
+		year       float64
+		yearWindow int
+		limit      int
+	}
+
+	plotIndex struct {
+		snapshot     string
+		interval     time.Duration
+		maxDocuments int
+		maxTerms     int
+	}
+
+	recommend struct {
+		interval   time.Duration
+		neighbours int
+	}
+
+	stats struct {
+		ttl          time.Duration
+		top          int
+		maxRefreshes int
+	}
+
+	grpc struct {
+		addr       string
+		reflection bool
+	}
+
+	graphql struct {
+		maxDepth      int
+		maxComplexity int
+		introspection bool
+	}
+
+	openapi struct {
+		validate bool
+	}
+
 	smtp struct {
 		host     string
 		port     int
@@ -44,11 +106,15 @@ type config struct {
 }
 
 type application struct {
-	config config
-	logger *newLogger.Logger
-	models data.Models
-	mailer smtp.Mailer
-	wg     sync.WaitGroup
+	config      config
+	logger      *newLogger.Logger
+	models      data.Models
+	mailer      smtp.Mailer
+	storage     storage.Storage
+	stats       *statsCache
+	recommender *recommender
+	plotIndex   *plotindex.Index
+	wg          sync.WaitGroup
 }
 
 func main() {
@@ -70,11 +136,66 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limit max burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enable", true, "Rate limit enabler")
 
+	flag.Float64Var(&cfg.search.similarityThreshold, "search-similarity-threshold", 0.3, "Title similarity for the fuzzy search fallback (0 disables it)")
+	flag.Float64Var(&cfg.search.autocompleteThreshold, "autocomplete-similarity-threshold", 0.4, "Title similarity for autocomplete suggestions")
+	flag.IntVar(&cfg.search.autocompleteLimit, "autocomplete-limit", 10, "Default number of autocomplete suggestions")
+	flag.Float64Var(&cfg.search.duplicateThreshold, "duplicate-similarity-threshold", 0.6, "Title similarity at which a new movie is reported as a duplicate (0 disables it)")
+
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory uploaded files are stored in")
+	flag.StringVar(&cfg.storage.baseURL, "storage-base-url", "/v1/media", "Public URL prefix of the stored files")
+	flag.Int64Var(&cfg.posters.maxBytes, "poster-max-bytes", 5<<20, "Max size of an uploaded poster in bytes")
+	flag.IntVar(&cfg.posters.maxPixels, "poster-max-pixels", 25_000_000, "Max width times height of an uploaded poster, bounds the memory needed to decode it")
+
+	flag.Float64Var(&cfg.similar.genres, "similar-weight-genres", 3, "Weight of shared genres in the similar movies score")
+	flag.Float64Var(&cfg.similar.actors, "similar-weight-actors", 2, "Weight of shared actors in the similar movies score")
+	flag.Float64Var(&cfg.similar.director, "similar-weight-director", 2, "Weight of the same director in the similar movies score")
+	flag.Float64Var(&cfg.similar.year, "similar-weight-year", 1, "Weight of the closeness in year in the similar movies score")
+	flag.IntVar(&cfg.similar.yearWindow, "similar-year-window", 15, "Year difference at which the year no longer adds to the similar movies score")
+	flag.IntVar(&cfg.similar.limit, "similar-limit", 10, "Default number of similar movies")
+
+	flag.StringVar(&cfg.plotIndex.snapshot, "plot-index-snapshot", "./plot-index.gob", "File the plot index is snapshotted to")
+	flag.DurationVar(&cfg.plotIndex.interval, "plot-index-snapshot-interval", 5*time.Minute, "How often the plot index is snapshotted when it changed")
+	flag.IntVar(&cfg.plotIndex.maxDocuments, "plot-index-max-documents", 200000, "Max number of plots kept in the plot index")
+	flag.IntVar(&cfg.plotIndex.maxTerms, "plot-index-max-terms", 64, "Max number of distinct terms kept per plot")
+
+	flag.DurationVar(&cfg.recommend.interval, "recommend-interval", time.Hour, "How often the recommendations are trained from the ratings")
+	flag.IntVar(&cfg.recommend.neighbours, "recommend-neighbours", 50, "Number of similar movies kept per movie for the recommendations")
+
+	flag.DurationVar(&cfg.stats.ttl, "stats-ttl", 5*time.Minute, "How often the cached movie statistics are refreshed")
+	flag.IntVar(&cfg.stats.top, "stats-top", 10, "Number of directors and actors in the movie statistics")
+	flag.IntVar(&cfg.stats.maxRefreshes, "stats-max-refreshes", 2, "Max number of movie statistics computed at the same time")
+
+	flag.StringVar(&cfg.grpc.addr, "grpc-addr", "localhost:4001", "gRPC server address (empty disables it)")
+	flag.BoolVar(&cfg.grpc.reflection, "grpc-reflection", false, "Register gRPC server reflection (defaults to on with -env dev)")
+
+	flag.IntVar(&cfg.graphql.maxDepth, "graphql-max-depth", 8, "Max nesting depth of a GraphQL query")
+	flag.IntVar(&cfg.graphql.maxComplexity, "graphql-max-complexity", 2000, "Max complexity of a GraphQL query, every field costs one per item of the lists around it")
+	flag.BoolVar(&cfg.graphql.introspection, "graphql-introspection", getEnv("GRAPHQL_INTROSPECTION") == "true", "Allow GraphQL schema introspection (defaults to GRAPHQL_INTROSPECTION)")
+
+	flag.BoolVar(&cfg.openapi.validate, "openapi-validate", false, "Reject requests which don't match the OpenAPI document, meant for development")
+
+	regeneratePosters := flag.Bool("regenerate-posters", false, "Regenerate the poster sizes of every movie and exit")
+	grant := flag.String("grant", "", "Grant permissions to a user and exit, as email=code,code like admin@example.com=movies:write")
+
 	flag.Parse()
 
+	// Reflection exposes the whole gRPC API, outside development it has to be asked for
+	reflectionSet := false
+	flag.Visit(func(f *flag.Flag) {
+		reflectionSet = reflectionSet || f.Name == "grpc-reflection"
+	})
+	if !reflectionSet {
+		cfg.grpc.reflection = cfg.env == "dev"
+	}
+
 	// Create a new logger instance
 	logger := newLogger.New(os.Stdout, newLogger.LevelInfo)
 
+	err := validateConfig(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -83,6 +204,11 @@ func main() {
 	defer db.Close()
 	logger.PrintInfo("Database connection established!", nil)
 
+	store, err := storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	app := &application{
 		config: cfg,
 		logger: logger,
@@ -94,6 +220,38 @@ func main() {
 			cfg.smtp.password,
 			cfg.smtp.sender,
 		),
+		storage:     store,
+		stats:       newStatsCache(cfg.stats.maxRefreshes),
+		recommender: &recommender{},
+		plotIndex: plotindex.New(plotindex.Options{
+			MaxDocuments:         cfg.plotIndex.maxDocuments,
+			MaxTermsPerDocument:  cfg.plotIndex.maxTerms,
+			MaxDocumentFrequency: plotindex.DefaultOptions.MaxDocumentFrequency,
+		}),
+	}
+
+	if *regeneratePosters {
+		err = app.regeneratePosterSizes()
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		return
+	}
+
+	if *grant != "" {
+		err = app.grantPermissions(*grant)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		return
+	}
+
+	// Every write through the movie model keeps the plot index up to date
+	app.models.Movie.Index = app.plotIndex
+
+	err = app.buildPlotIndex()
+	if err != nil {
+		logger.PrintError(err, nil)
 	}
 
 	err = app.serve()
@@ -102,6 +260,25 @@ func main() {
 	}
 }
 
+// validateConfig rejects the flag values the server can't run with, the refresh
+// intervals feed time.NewTicker which panics on anything but a positive duration
+func validateConfig(cfg config) error {
+	if cfg.stats.ttl <= 0 {
+		return errors.New("-stats-ttl must be greater than zero")
+	}
+	if cfg.stats.maxRefreshes < 1 {
+		return errors.New("-stats-max-refreshes must be at least 1")
+	}
+	if cfg.recommend.interval <= 0 {
+		return errors.New("-recommend-interval must be greater than zero")
+	}
+	if cfg.plotIndex.interval <= 0 {
+		return errors.New("-plot-index-snapshot-interval must be greater than zero")
+	}
+
+	return nil
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.db.dsn)
 	if err != nil {
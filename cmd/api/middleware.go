@@ -1,10 +1,14 @@
 package main
 
 import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
 	"fmt"
 	"golang.org/x/time/rate"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -96,3 +100,96 @@ func (app *application) requestLogger(next http.Handler) http.Handler {
 		next.ServeHTTP(res, req)
 	})
 }
+
+// authenticate adds the user owning the bearer token to the request context,
+// or the AnonymousUser when the request has no Authorization header
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// The response varies with the Authorization header, tell the caches about it
+		res.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := req.Header.Get("Authorization")
+
+		if authorizationHeader == "" {
+			req = app.contextSetUser(req, data.AnonymousUser)
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		// Expect the header in the format "Bearer <token>"
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(res, req)
+			return
+		}
+
+		token := headerParts[1]
+
+		v := validator.New()
+		if data.ValidateTokenPlainText(v, token); !v.Valid() {
+			app.invalidAuthenticationTokenResponse(res, req)
+			return
+		}
+
+		user, err := app.models.User.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRecordsFound) {
+				app.invalidAuthenticationTokenResponse(res, req)
+				return
+			}
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		req = app.contextSetUser(req, user)
+		next.ServeHTTP(res, req)
+	})
+}
+
+// requireAuthenticatedUser rejects the request unless it was made with a valid authentication token
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		user := app.contextGetUser(req)
+
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(res, req)
+			return
+		}
+
+		next.ServeHTTP(res, req)
+	}
+}
+
+// requireAuth wraps next in the check the auth of a route asks for
+func (app *application) requireAuth(auth string, next http.HandlerFunc) http.HandlerFunc {
+	switch auth {
+	case authNone:
+		return next
+	case authUser:
+		return app.requireAuthenticatedUser(next)
+	default:
+		return app.requirePermission(auth, next)
+	}
+}
+
+// requirePermission rejects the request unless the authenticated user was granted the permission code
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(res http.ResponseWriter, req *http.Request) {
+		user := app.contextGetUser(req)
+
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			app.notPermittedResponse(res, req)
+			return
+		}
+
+		next.ServeHTTP(res, req)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
@@ -6,8 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// movieSortSafeList are the sort values of a movie listing, shared by REST and GraphQL
+var movieSortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-runtime", "relevance", "rating", "-rating"}
+
 func (app *application) createMovieHandler(res http.ResponseWriter, req *http.Request) {
 	type CreateMovieDTO struct {
 		Title     string   `json:"title"`
@@ -18,6 +23,8 @@ func (app *application) createMovieHandler(res http.ResponseWriter, req *http.Re
 		Actors    []string `json:"actors"`
 		Plot      string   `json:"plot"`
 		PosterURL string   `json:"poster_url"`
+		// ExternalIDs maps a source like imdb to the id of the movie there
+		ExternalIDs data.ExternalIDs `json:"external_ids"`
 	}
 
 	body := new(CreateMovieDTO)
@@ -29,27 +36,60 @@ func (app *application) createMovieHandler(res http.ResponseWriter, req *http.Re
 	}
 
 	movie := &data.Movie{
-		Title:     body.Title,
-		Year:      body.Year,
-		Runtime:   body.Runtime,
-		Genres:    body.Genres,
-		Director:  body.Director,
-		Actors:    body.Actors,
-		Plot:      body.Plot,
-		PosterURL: body.PosterURL,
+		Title:       body.Title,
+		Year:        body.Year,
+		Runtime:     body.Runtime,
+		Genres:      body.Genres,
+		Director:    body.Director,
+		Actors:      body.Actors,
+		Plot:        body.Plot,
+		PosterURL:   body.PosterURL,
+		ExternalIDs: body.ExternalIDs,
+	}
+
+	taxonomy, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
 	}
 
 	validate := validator.New()
 
-	data.ValidateMovie(validate, movie)
+	data.ValidateMovie(validate, movie, taxonomy)
+	data.ValidateExternalIDs(validate, movie.ExternalIDs)
 
 	if !validate.Valid() {
 		app.failedValidationResponse(res, req, validate.Errors)
 		return
 	}
 
+	// ?force=true skips the duplicate check for movies which really do share a title
+	force := app.readBool(req.URL.Query(), "force", false, validate)
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	if !force && app.config.search.duplicateThreshold > 0 {
+		candidates, err := app.models.Movie.FindDuplicates(movie, app.config.search.duplicateThreshold)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		if len(candidates) > 0 {
+			app.duplicateMovieResponse(res, req, candidates)
+			return
+		}
+	}
+
 	err = app.models.Movie.Insert(movie)
 	if err != nil {
+		if errors.Is(err, data.ErrDuplicateExternalID) {
+			validate.AddError("external_ids", "an external id is already mapped to another movie")
+			app.failedValidationResponse(res, req, validate.Errors)
+			return
+		}
 		app.internalServerErrorResponse(res, req, err)
 		return
 	}
@@ -80,22 +120,54 @@ func (app *application) showMovieHandler(res http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	movie, err := app.models.Movie.Get(id)
+	validate := validator.New()
+
+	fields := app.readCSV(req.URL.Query(), "fields", []string{})
+	if data.ValidateFields(validate, fields, data.MovieFieldSafeList); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	movie, err := app.models.Movie.GetFields(id, fields)
 
 	if err != nil {
 		if errors.Is(err, data.ErrNoRecordsFound) {
-			app.notFoundResponse(res, req)
+			app.redirectMergedMovie(res, req, id)
 			return
 		}
 		app.internalServerErrorResponse(res, req, err)
 		return
 	}
 
+	err = app.attachListStatus(req, movie)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	err = app.models.Translations.Localize(app.readLocales(req), movie)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+	if movie.Locale != "" {
+		headers.Set("Content-Language", movie.Locale)
+	}
+
+	result, err := data.SelectFields(movie, fields)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
 	response := data.NewResponse()
-	response.Result = movie
+	response.Result = result
 	response.Message = "Movie Retrieved Successfully"
 
-	err = app.writeJSON(res, 200, response, nil)
+	err = app.writeJSON(res, 200, response, headers)
 
 	if err != nil {
 		app.internalServerErrorResponse(res, req, err)
@@ -104,9 +176,9 @@ func (app *application) showMovieHandler(res http.ResponseWriter, req *http.Requ
 
 func (app *application) showMoviesHandler(res http.ResponseWriter, req *http.Request) {
 	var requestQuery struct {
-		Title  string   `json:"title"`
-		Genres []string `json:"genres"`
+		data.MovieQuery
 		data.Filters
+		Facets []string `json:"facets"`
 	}
 
 	validate := validator.New()
@@ -115,32 +187,97 @@ func (app *application) showMoviesHandler(res http.ResponseWriter, req *http.Req
 	qs := req.URL.Query()
 
 	requestQuery.Title = app.readString(qs, "title", "")
-	requestQuery.Genres = app.readCSV(qs, "genres", []string{})
+	requestQuery.Search = app.readString(qs, "q", "")
+	requestQuery.SimilarityThreshold = app.config.search.similarityThreshold
+	// genres is kept for existing clients and behaves like genres_all
+	requestQuery.GenresAll = app.readCSV(qs, "genres_all", app.readCSV(qs, "genres", []string{}))
+	requestQuery.GenresAny = app.readCSV(qs, "genres_any", []string{})
+	requestQuery.YearMin = app.readInt(qs, "year_min", 0, validate)
+	requestQuery.YearMax = app.readInt(qs, "year_max", 0, validate)
+	requestQuery.RuntimeMin = app.readInt(qs, "runtime_min", 0, validate)
+	requestQuery.RuntimeMax = app.readInt(qs, "runtime_max", 0, validate)
+	requestQuery.Director = app.readString(qs, "director", "")
+	requestQuery.Actor = app.readString(qs, "actor", "")
+	requestQuery.CreatedAfter = app.readTime(qs, "created_after", time.Time{}, validate)
+	requestQuery.CreatedBefore = app.readTime(qs, "created_before", time.Time{}, validate)
+	requestQuery.Region = strings.ToUpper(app.readString(qs, "region", ""))
+	requestQuery.Certification = app.readString(qs, "certification", "")
+	requestQuery.MinVotes = app.readInt(qs, "min_votes", 0, validate)
+	requestQuery.Locales = app.readLocales(req)
 
 	requestQuery.Filters.Page = app.readInt(qs, "page", 1, validate)
 	requestQuery.Filters.PageSize = app.readInt(qs, "page_size", 10, validate)
+	requestQuery.Filters.Cursor = app.readString(qs, "cursor", "")
+	requestQuery.Filters.IncludeTotal = app.readBool(qs, "include_total", true, validate)
 
 	requestQuery.Sort = app.readString(qs, "sort", "id")
-	requestQuery.SortSafeList = []string{"id", "title", "year", "runtime", "-id", "-title", "-runtime"}
+	requestQuery.SortSafeList = movieSortSafeList
+
+	requestQuery.Fields = app.readCSV(qs, "fields", []string{})
+	requestQuery.FieldSafeList = data.MovieFieldSafeList
+
+	requestQuery.Facets = app.readCSV(qs, "facets", []string{})
+	data.ValidateFacets(validate, requestQuery.Facets)
+
+	data.ValidateMovieQuery(validate, &requestQuery.MovieQuery)
+	validate.Check(requestQuery.Sort != "relevance" || requestQuery.Search != "", "sort", "Relevance Sort Requires q")
 
 	if data.ValidateFilters(validate, &requestQuery.Filters); !validate.Valid() {
 		app.failedValidationResponse(res, req, validate.Errors)
 		return
 	}
 
-	movies, paginationMetadata, err := app.models.Movie.GetAll(requestQuery.Title, requestQuery.Genres, requestQuery.Filters)
+	movies, paginationMetadata, fuzzy, err := app.models.Movie.GetAll(requestQuery.MovieQuery, requestQuery.Filters)
+
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	err = app.attachListStatus(req, movies...)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
 
+	err = app.models.Translations.Localize(requestQuery.Locales, movies...)
 	if err != nil {
 		app.internalServerErrorResponse(res, req, err)
 		return
 	}
 
+	// Shape every movie down to the sparse fieldset when one was requested
+	var result interface{} = movies
+	if len(requestQuery.Fields) > 0 {
+		shaped := make([]interface{}, 0, len(movies))
+		for _, movie := range movies {
+			selected, err := data.SelectFields(movie, requestQuery.Fields)
+			if err != nil {
+				app.internalServerErrorResponse(res, req, err)
+				return
+			}
+			shaped = append(shaped, selected)
+		}
+		result = shaped
+	}
+
 	response := data.NewResponse()
-	response.Result = movies
+	response.Result = result
 	response.Message = "Movies Fetched Successfully"
 	response.Pagination = &paginationMetadata
 
-	err = app.writeJSON(res, 200, response, nil)
+	if len(requestQuery.Facets) > 0 {
+		response.Facets, err = app.models.Movie.Facets(req.Context(), requestQuery.MovieQuery, fuzzy, requestQuery.Facets)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Vary", "Accept-Language")
+
+	err = app.writeJSON(res, 200, response, headers)
 
 	if err != nil {
 		app.internalServerErrorResponse(res, req, err)
@@ -148,6 +285,40 @@ func (app *application) showMoviesHandler(res http.ResponseWriter, req *http.Req
 	}
 }
 
+func (app *application) autocompleteMoviesHandler(res http.ResponseWriter, req *http.Request) {
+	validate := validator.New()
+	qs := req.URL.Query()
+
+	prefix := app.readString(qs, "prefix", "")
+	limit := app.readInt(qs, "limit", app.config.search.autocompleteLimit, validate)
+
+	validate.Check(prefix != "", "prefix", "prefix must be provided")
+	validate.Check(len(prefix) <= 100, "prefix", "prefix max length is 100 characters")
+	validate.Check(limit > 0, "limit", "limit is invalid")
+	validate.Check(limit <= 25, "limit", "limit exceed maximum")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movie.Autocomplete(prefix, limit, app.config.search.autocompleteThreshold)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = suggestions
+	response.Message = "Movie Suggestions Fetched Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
 func (app *application) updateMovieHandler(res http.ResponseWriter, req *http.Request) {
 	id, err := app.readIDParam(req)
 	if err != nil {
@@ -166,6 +337,8 @@ func (app *application) updateMovieHandler(res http.ResponseWriter, req *http.Re
 		Actors    []string `json:"actors"`
 		Plot      *string  `json:"plot"`
 		PosterURL *string  `json:"poster_url"`
+		// ExternalIDs only changes the sources it holds, an empty id removes the mapping
+		ExternalIDs data.ExternalIDs `json:"external_ids"`
 	}
 
 	body := new(UpdateMovieDTO)
@@ -173,13 +346,14 @@ func (app *application) updateMovieHandler(res http.ResponseWriter, req *http.Re
 	err = app.readJSON(res, req, &body)
 	if err != nil {
 		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	movie, err := app.models.Movie.Get(id)
 
 	if err != nil {
 		if errors.Is(err, data.ErrNoRecordsFound) {
-			app.notFoundResponse(res, req)
+			app.redirectMergedMovie(res, req, id)
 			return
 		}
 		app.internalServerErrorResponse(res, req, err)
@@ -202,10 +376,30 @@ func (app *application) updateMovieHandler(res http.ResponseWriter, req *http.Re
 		movie.Genres = body.Genres
 	}
 
+	// Director and actors are matched to people and become credits of the movie
+	if body.Director != nil {
+		movie.Director = *body.Director
+	}
+
+	if body.Actors != nil {
+		movie.Actors = body.Actors
+	}
+
+	if body.Plot != nil {
+		movie.Plot = *body.Plot
+	}
+
 	if body.PosterURL != nil {
 		movie.PosterURL = *body.PosterURL
 	}
 
+	for source, id := range body.ExternalIDs {
+		if movie.ExternalIDs == nil {
+			movie.ExternalIDs = data.ExternalIDs{}
+		}
+		movie.ExternalIDs[source] = id
+	}
+
 	//movie = &data.Movie{
 	//	Title:     *body.Title,
 	//	Year:      *body.Year,
@@ -218,19 +412,33 @@ func (app *application) updateMovieHandler(res http.ResponseWriter, req *http.Re
 	//	ID:        id,
 	//}
 
+	taxonomy, err := app.models.Genres.Taxonomy()
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
 	validate := validator.New()
 
-	if data.ValidateMovie(validate, movie); !validate.Valid() {
+	data.ValidateMovie(validate, movie, taxonomy)
+	data.ValidateExternalIDs(validate, body.ExternalIDs)
+
+	if !validate.Valid() {
 		app.failedValidationResponse(res, req, validate.Errors)
+		return
 	}
 
 	err = app.models.Movie.Update(movie)
 	if err != nil {
-		if errors.Is(err, data.ErrEditConflict) {
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			validate.AddError("external_ids", "an external id is already mapped to another movie")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(res, req)
-			return
+		default:
+			app.internalServerErrorResponse(res, req, err)
 		}
-		app.internalServerErrorResponse(res, req, err)
 		return
 	}
 
@@ -256,7 +464,7 @@ func (app *application) deleteMovieHandler(res http.ResponseWriter, req *http.Re
 	err = app.models.Movie.Delete(id)
 	if err != nil {
 		if errors.Is(err, data.ErrNoRecordsFound) {
-			app.notFoundResponse(res, req)
+			app.redirectMergedMovie(res, req, id)
 			return
 		}
 		app.internalServerErrorResponse(res, req, err)
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// jsonSchema is a JSON Schema as used by OpenAPI 3.1
+type jsonSchema map[string]interface{}
+
+// apiParam is a query parameter of an operation, path parameters come from the route pattern
+type apiParam struct {
+	name        string
+	schema      jsonSchema
+	description string
+	required    bool
+}
+
+// apiOperation documents one route of the route table
+type apiOperation struct {
+	summary     string
+	description string
+	tag         string
+	query       []apiParam
+	// body is the JSON request body, optionalBody allows leaving it out entirely
+	body         jsonSchema
+	optionalBody bool
+	// content replaces the JSON request body with other media types, like a poster upload
+	content map[string]jsonSchema
+	// status is the success status, 200 when zero
+	status int
+	// result is the schema of the result field of the response envelope, paginated
+	// adds the pagination field to it
+	result    jsonSchema
+	paginated bool
+	// accepted documents the 202 of results which are computed in the background
+	accepted bool
+	// response replaces the response envelope for routes which don't use it
+	response map[string]interface{}
+	// errors are the statuses besides the ones derived from the route, see responses,
+	// a status below 400 is documented as a redirect
+	errors []int
+}
+
+func str() jsonSchema     { return jsonSchema{"type": "string"} }
+func integer() jsonSchema { return jsonSchema{"type": "integer"} }
+func number() jsonSchema  { return jsonSchema{"type": "number"} }
+func boolean() jsonSchema { return jsonSchema{"type": "boolean"} }
+
+func enum(values ...string) jsonSchema {
+	return jsonSchema{"type": "string", "enum": values}
+}
+
+func arrayOf(items jsonSchema) jsonSchema {
+	return jsonSchema{"type": "array", "items": items}
+}
+
+func mapOf(values jsonSchema) jsonSchema {
+	return jsonSchema{"type": "object", "additionalProperties": values}
+}
+
+// object builds an object schema from alternating property names and schemas, the
+// names listed in required have to be present
+func object(required []string, properties ...interface{}) jsonSchema {
+	props := jsonSchema{}
+	for i := 0; i+1 < len(properties); i += 2 {
+		props[properties[i].(string)] = properties[i+1]
+	}
+
+	schema := jsonSchema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func ref(name string) jsonSchema {
+	return jsonSchema{"$ref": "#/components/schemas/" + name}
+}
+
+// nullable allows null next to the schema, the way a nil pointer is written to JSON
+func nullable(schema jsonSchema) jsonSchema {
+	if t, ok := schema["type"].(string); ok {
+		copied := jsonSchema{}
+		for k, v := range schema {
+			copied[k] = v
+		}
+		copied["type"] = []string{t, "null"}
+		return copied
+	}
+	return jsonSchema{"anyOf": []jsonSchema{schema, {"type": "null"}}}
+}
+
+func describe(schema jsonSchema, description string) jsonSchema {
+	schema["description"] = description
+	return schema
+}
+
+// schemaRegistry turns Go types into schemas, named structs become components which
+// are referenced, so the document follows the json tags the API actually writes
+type schemaRegistry struct {
+	schemas map[string]jsonSchema
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	dateType = reflect.TypeOf(data.Date{})
+)
+
+// of returns the schema of the value, a pointer is documented as the value it points to
+func (s *schemaRegistry) of(v interface{}) jsonSchema {
+	return s.schema(elem(reflect.TypeOf(v)))
+}
+
+// elem drops a pointer, the elements of slices and maps are never written as null
+func elem(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+func (s *schemaRegistry) schema(t reflect.Type) jsonSchema {
+	switch t {
+	case timeType:
+		return jsonSchema{"type": "string", "format": "date-time"}
+	case dateType:
+		return jsonSchema{"type": "string", "format": "date"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(s.schema(t.Elem()))
+	case reflect.String:
+		return str()
+	case reflect.Bool:
+		return boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return integer()
+	case reflect.Float32, reflect.Float64:
+		return number()
+	case reflect.Slice, reflect.Array:
+		return arrayOf(s.schema(elem(t.Elem())))
+	case reflect.Map:
+		return mapOf(s.schema(elem(t.Elem())))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+
+		name := []rune(t.Name())
+		name[0] = unicode.ToUpper(name[0])
+
+		if _, ok := s.schemas[string(name)]; !ok {
+			// The placeholder stops recursion through self referencing types
+			s.schemas[string(name)] = jsonSchema{}
+			s.schemas[string(name)] = s.object(t)
+		}
+		return ref(string(name))
+	}
+
+	// interface{} holds anything
+	return jsonSchema{}
+}
+
+func (s *schemaRegistry) object(t reflect.Type) jsonSchema {
+	properties := jsonSchema{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = s.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := jsonSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// errorExamples are the messages of the error responses in errors.go
+var errorExamples = map[int]string{
+	http.StatusBadRequest:            "Body contains badly-formed JSON",
+	http.StatusUnauthorized:          "Invalid or missing authentication token",
+	http.StatusForbidden:             "Your user account doesn't have the necessary permissions to access this resource",
+	http.StatusNotFound:              "The requested resource could not be found",
+	http.StatusConflict:              "Unable to update the record due to an edit conflict, please try again",
+	http.StatusRequestEntityTooLarge: "The poster must not be larger than 5242880 bytes",
+	http.StatusUnsupportedMediaType:  "The poster must be a JPEG, PNG or WebP image",
+	http.StatusTooManyRequests:       "Error too many request",
+	http.StatusInternalServerError:   "The server encountered a problem and cannot process incoming request",
+	http.StatusServiceUnavailable:    "The server is busy, please retry shortly",
+}
+
+var pathParamRX = regexp.MustCompile(`[:*]([a-z_]+)`)
+
+// openAPIPath turns a httprouter pattern like /v1/movies/:id into /v1/movies/{id}
+func openAPIPath(pattern string) string {
+	return pathParamRX.ReplaceAllString(pattern, "{$1}")
+}
+
+func operationKey(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// openAPISpec is the OpenAPI document with the operations and schemas it was built from,
+// the request validation works on those instead of the document
+type openAPISpec struct {
+	document   map[string]interface{}
+	operations map[string]apiOperation
+	schemas    map[string]jsonSchema
+}
+
+// buildOpenAPI builds the OpenAPI document from the route table and the operations
+func (app *application) buildOpenAPI() (*openAPISpec, error) {
+	registry := &schemaRegistry{schemas: map[string]jsonSchema{}}
+	operations := apiOperations(registry)
+
+	registry.of(data.Response{})
+	registry.schemas["Error"] = object([]string{"status", "statusCode", "error"},
+		"status", enumBool(false),
+		"statusCode", integer(),
+		"error", jsonSchema{"description": "A message, or for some errors an object with the details"},
+	)
+	registry.schemas["ValidationError"] = object([]string{"status", "statusCode", "error"},
+		"status", enumBool(false),
+		"statusCode", enumInt(http.StatusUnprocessableEntity),
+		"error", describe(mapOf(str()), "The failed fields and why they failed"),
+	)
+
+	paths := map[string]map[string]interface{}{}
+
+	for _, r := range app.routeTable() {
+		op, ok := operations[operationKey(r.method, r.pattern)]
+		if !ok {
+			return nil, fmt.Errorf("openapi: no operation for %s", operationKey(r.method, r.pattern))
+		}
+
+		path := openAPIPath(r.pattern)
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(r.method)] = op.document(r)
+	}
+
+	document := map[string]interface{}{
+		"openapi":           "3.1.0",
+		"jsonSchemaDialect": "https://spec.openapis.org/oas/3.1/dialect/base",
+		"info": map[string]interface{}{
+			"title":   "Movies API",
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": registry.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+
+	return &openAPISpec{document: document, operations: operations, schemas: registry.schemas}, nil
+}
+
+func enumBool(value bool) jsonSchema {
+	return jsonSchema{"type": "boolean", "const": value}
+}
+
+func enumInt(value int) jsonSchema {
+	return jsonSchema{"type": "integer", "const": value}
+}
+
+// pathParams returns the parameters of a route pattern in order
+func pathParams(pattern string) []string {
+	var params []string
+	for _, match := range pathParamRX.FindAllStringSubmatch(pattern, -1) {
+		params = append(params, match[1])
+	}
+	return params
+}
+
+// pathParamSchema is the schema of a path parameter, only ids are numbers
+func pathParamSchema(name string) jsonSchema {
+	if name == "external_id" || name != "id" && !strings.HasSuffix(name, "_id") {
+		return str()
+	}
+	return jsonSchema{"type": "integer", "minimum": 1}
+}
+
+// document writes the operation as an OpenAPI operation object
+func (op apiOperation) document(r route) map[string]interface{} {
+	doc := map[string]interface{}{
+		"operationId": operationID(r),
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+	}
+
+	description := op.description
+	if r.auth != "" && r.auth != authUser {
+		description = strings.TrimSpace(description + "\n\nRequires the " + r.auth + " permission.")
+	}
+	if description != "" {
+		doc["description"] = description
+	}
+
+	var parameters []interface{}
+
+	for _, name := range pathParams(r.pattern) {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": pathParamSchema(name),
+		})
+	}
+
+	for _, param := range op.query {
+		p := map[string]interface{}{"name": param.name, "in": "query", "schema": param.schema}
+		if param.required {
+			p["required"] = true
+		}
+		if param.description != "" {
+			p["description"] = param.description
+		}
+		parameters = append(parameters, p)
+	}
+
+	if len(parameters) > 0 {
+		doc["parameters"] = parameters
+	}
+
+	if op.body != nil || op.content != nil {
+		content := map[string]interface{}{}
+		if op.body != nil {
+			content["application/json"] = map[string]interface{}{"schema": op.body}
+		}
+		for mediaType, schema := range op.content {
+			content[mediaType] = map[string]interface{}{"schema": schema}
+		}
+		doc["requestBody"] = map[string]interface{}{"required": !op.optionalBody, "content": content}
+	}
+
+	if r.auth != "" {
+		doc["security"] = []interface{}{map[string]interface{}{"bearer": []string{}}}
+	}
+
+	doc["responses"] = op.responses(r)
+
+	return doc
+}
+
+// responses documents the success response and every error the route can answer with
+func (op apiOperation) responses(r route) map[string]interface{} {
+	responses := map[string]interface{}{}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if op.response != nil {
+		responses[fmt.Sprint(status)] = op.response
+	} else {
+		result := op.result
+		if result == nil {
+			result = jsonSchema{}
+		}
+
+		properties := jsonSchema{"result": result}
+		if op.paginated {
+			properties["pagination"] = ref("PaginationMetadata")
+		}
+
+		responses[fmt.Sprint(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": jsonSchema{"allOf": []jsonSchema{ref("Response"), {"properties": properties}}},
+				},
+			},
+		}
+	}
+
+	if op.accepted {
+		responses[fmt.Sprint(http.StatusAccepted)] = acceptedResponse()
+	}
+
+	errors := map[int]bool{http.StatusTooManyRequests: true, http.StatusInternalServerError: true}
+
+	if len(pathParams(r.pattern)) > 0 {
+		errors[http.StatusNotFound] = true
+	}
+	// A movie merged into another one redirects to it, see redirectMergedMovie
+	if strings.HasPrefix(r.pattern, "/v1/movies/:id") && r.pattern != "/v1/movies/:id/merge" {
+		if r.method == http.MethodGet {
+			errors[http.StatusMovedPermanently] = true
+		} else {
+			errors[http.StatusPermanentRedirect] = true
+		}
+	}
+	if op.body != nil {
+		errors[http.StatusBadRequest] = true
+	}
+	if op.body != nil || len(op.query) > 0 {
+		errors[http.StatusUnprocessableEntity] = true
+	}
+	if r.auth != "" {
+		errors[http.StatusUnauthorized] = true
+	}
+	if r.auth != "" && r.auth != authUser {
+		errors[http.StatusForbidden] = true
+	}
+	for _, status := range op.errors {
+		errors[status] = true
+	}
+
+	for status := range errors {
+		if status < http.StatusBadRequest {
+			responses[fmt.Sprint(status)] = map[string]interface{}{
+				"description": http.StatusText(status),
+				"headers": map[string]interface{}{
+					"Location": map[string]interface{}{"schema": str()},
+				},
+			}
+			continue
+		}
+
+		schema := ref("Error")
+		if status == http.StatusUnprocessableEntity {
+			schema = ref("ValidationError")
+		}
+
+		response := map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schema},
+			},
+		}
+		if example, ok := errorExamples[status]; ok {
+			response["content"].(map[string]interface{})["application/json"].(map[string]interface{})["example"] =
+				map[string]interface{}{"status": false, "statusCode": status, "error": example}
+		}
+
+		responses[fmt.Sprint(status)] = response
+	}
+
+	return responses
+}
+
+// operationID names an operation after its handler route, like get-movies-id-similar
+func operationID(r route) string {
+	segments := []string{strings.ToLower(r.method)}
+	for _, segment := range strings.Split(strings.TrimPrefix(r.pattern, "/v1/"), "/") {
+		segment = strings.TrimLeft(segment, ":*")
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "_", "-"), ".", "-")
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, "-")
+}
+
+// openAPICache holds the spec once it is built, it only depends on the code
+var openAPICache struct {
+	once sync.Once
+	spec *openAPISpec
+	err  error
+}
+
+func (app *application) openAPI() (*openAPISpec, error) {
+	openAPICache.once.Do(func() {
+		openAPICache.spec, openAPICache.err = app.buildOpenAPI()
+	})
+	return openAPICache.spec, openAPICache.err
+}
+
+// showOpenAPIHandler serves the OpenAPI document of the API
+func (app *application) showOpenAPIHandler(res http.ResponseWriter, req *http.Request) {
+	spec, err := app.openAPI()
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	err = app.writeJSON(res, http.StatusOK, spec.document, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// showDocsHandler serves the page which renders the OpenAPI document, it is embedded
+// in the binary and loads nothing but the document
+func (app *application) showDocsHandler(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	res.WriteHeader(http.StatusOK)
+	res.Write(docsPage)
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"net/http"
+)
+
+// pageParams are the query parameters of the paginated listings
+func pageParams(sortSafeList []string, defaultSort string) []apiParam {
+	params := []apiParam{
+		{name: "page", schema: jsonSchema{"type": "integer", "minimum": 1, "maximum": 10_000, "default": 1}, description: "Only capped without a cursor"},
+		{name: "page_size", schema: jsonSchema{"type": "integer", "minimum": 1, "maximum": 50, "default": 10}},
+		{name: "cursor", schema: str(), description: "The next_cursor or prev_cursor of the previous page, it replaces page"},
+		{name: "include_total", schema: jsonSchema{"type": "boolean", "default": true}, description: "Leave out total_records and last_page to skip counting"},
+	}
+
+	if len(sortSafeList) > 1 {
+		sort := enum(sortSafeList...)
+		sort["default"] = defaultSort
+		params = append(params, apiParam{name: "sort", schema: sort, description: "A leading - sorts descending"})
+	}
+
+	return params
+}
+
+func csvParam(name, description string) apiParam {
+	return apiParam{name: name, schema: str(), description: description + ", comma separated"}
+}
+
+func limitParam(defaultLimit, maxLimit int) apiParam {
+	return apiParam{name: "limit", schema: jsonSchema{"type": "integer", "minimum": 1, "maximum": maxLimit, "default": defaultLimit}}
+}
+
+func timeParam(name, description string) apiParam {
+	return apiParam{name: name, schema: str(), description: description + ", as a RFC 3339 timestamp or a date"}
+}
+
+// acceptedResponse is the response of a result computed in the background, the client
+// retries after the seconds in Retry-After
+func acceptedResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"description": "The result is being computed, retry after the Retry-After header",
+		"headers": map[string]interface{}{
+			"Retry-After": map[string]interface{}{"schema": integer()},
+		},
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": ref("Response")},
+		},
+	}
+}
+
+// apiOperations documents every route of routeTable, keyed by operationKey
+func apiOperations(s *schemaRegistry) map[string]apiOperation {
+	movie := s.of(data.Movie{})
+	movies := arrayOf(movie)
+	movieInput := object(nil,
+		"title", str(),
+		"year", integer(),
+		"runtime", describe(integer(), "In minutes"),
+		"genres", describe(arrayOf(str()), "Genre slugs or aliases"),
+		"director", str(),
+		"actors", arrayOf(str()),
+		"plot", str(),
+		"poster_url", str(),
+		"external_ids", describe(mapOf(str()), "The id of the movie in a source like imdb"),
+	)
+
+	movieQuery := []apiParam{
+		{name: "title", schema: str(), description: "Matches titles similar to the value"},
+		{name: "q", schema: str(), description: "Full text search over title and plot, adds highlights"},
+		csvParam("genres", "Same as genres_all, kept for existing clients"),
+		csvParam("genres_all", "Movies in every one of the genres"),
+		csvParam("genres_any", "Movies in any of the genres"),
+		{name: "year_min", schema: integer()},
+		{name: "year_max", schema: integer()},
+		{name: "runtime_min", schema: integer()},
+		{name: "runtime_max", schema: integer()},
+		timeParam("created_after", "Movies created after"),
+		timeParam("created_before", "Movies created before"),
+		{name: "director", schema: str()},
+		{name: "actor", schema: str()},
+		{name: "region", schema: str(), description: "An ISO 3166-1 alpha-2 code, limits certification to the region"},
+		{name: "certification", schema: str()},
+		{name: "min_votes", schema: integer(), description: "Movies with at least this many ratings"},
+		csvParam("fields", "Only writes these fields of each movie"),
+		csvParam("facets", "Counts the matching movies by genres, decade or runtime_bucket"),
+	}
+
+	movieQuery = append(movieQuery, pageParams(movieSortSafeList, "id")...)
+
+	collectionInput := object(nil,
+		"title", str(),
+		"description", str(),
+		"visibility", enum(data.VisibilityPublic, data.VisibilityPrivate, data.VisibilityUnlisted),
+	)
+	releaseInput := object(nil,
+		"country", describe(str(), "An ISO 3166-1 alpha-2 code"),
+		"release_date", jsonSchema{"type": "string", "format": "date"},
+		"release_type", enum(data.ReleaseTheatrical, data.ReleaseDigital, data.ReleaseFestival),
+		"certification", str(),
+	)
+	listEntry := s.of(data.ListEntry{})
+	collection := s.of(data.Collection{})
+
+	operations := map[string]apiOperation{
+		"GET /v1/healthcheck": {
+			summary: "Report that the server is available", tag: "health",
+			response: map[string]interface{}{
+				"description": "The server is available",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": object([]string{"status", "enviroment", "version"},
+							"status", str(), "enviroment", str(), "version", str()),
+					},
+				},
+			},
+		},
+
+		"GET /v1/movies": {
+			summary: "List movies", tag: "movies",
+			query: movieQuery, result: movies, paginated: true,
+		},
+		"POST /v1/movies": {
+			summary: "Create a movie", tag: "movies",
+			description: "Answers with conflict and the candidates when the movie looks like one which already exists.",
+			query:       []apiParam{{name: "force", schema: boolean(), description: "Creates the movie even when it looks like a duplicate"}},
+			body:        movieInput, status: http.StatusCreated, result: movie,
+			errors: []int{http.StatusConflict},
+		},
+		"GET /v1/movies/autocomplete": {
+			summary: "Suggest movie titles for a prefix", tag: "movies",
+			query:  []apiParam{{name: "prefix", schema: str(), required: true}, limitParam(10, 25)},
+			result: arrayOf(s.of(data.MovieSuggestion{})),
+		},
+		"GET /v1/movies/lookup": {
+			summary: "Find a movie by its id in an external source", tag: "movies",
+			query: []apiParam{
+				{name: "source", schema: str(), required: true, description: "Like imdb"},
+				{name: "id", schema: str(), required: true},
+			},
+			result: movie, errors: []int{http.StatusNotFound},
+		},
+		"GET /v1/movies/discover": {
+			summary: "Rank movies by how close their plot is to a text", tag: "movies",
+			query:  []apiParam{{name: "about", schema: str(), required: true}, limitParam(10, 50)},
+			result: arrayOf(s.of(plotMatch{})),
+		},
+		"GET /v1/movies/:id": {
+			summary: "Show a movie", tag: "movies",
+			description: "A movie merged into another one redirects to it. The title and plot are translated for Accept-Language.",
+			query:       []apiParam{csvParam("fields", "Only writes these fields")},
+			result:      movie,
+		},
+		"PATCH /v1/movies/:id": {
+			summary: "Update a movie", tag: "movies",
+			body: movieInput, result: movie, errors: []int{http.StatusConflict},
+		},
+		"PUT /v1/external/:source/:external_id": {
+			summary: "Create or update the movie with an external id", tag: "movies",
+			description: "Answers with created when the movie didn't exist.",
+			body:        movieInput, result: movie,
+		},
+		"DELETE /v1/movies/:id": {
+			summary: "Delete a movie", tag: "movies",
+			result: object([]string{"id"}, "id", integer()),
+		},
+		"POST /v1/movies/:id/merge": {
+			summary: "Merge a duplicate into another movie", tag: "movies",
+			body:   object([]string{"into"}, "into", describe(integer(), "The id of the movie which is kept")),
+			result: movie,
+		},
+
+		"GET /v1/movies/:id/similar": {
+			summary: "List movies with similar metadata", tag: "movies",
+			query:  []apiParam{limitParam(10, 50)},
+			result: arrayOf(s.of(data.SimilarMovie{})),
+		},
+		"GET /v1/movies/:id/similar-plot": {
+			summary: "List movies with a similar plot", tag: "movies",
+			query:  []apiParam{limitParam(10, 50)},
+			result: arrayOf(s.of(plotMatch{})),
+		},
+
+		"PUT /v1/movies/:id/poster": {
+			summary: "Upload the poster of a movie", tag: "media",
+			description: "The poster is either the raw body or the poster field of a multipart form, JPEG, PNG and WebP are accepted.",
+			content: map[string]jsonSchema{
+				"image/*":             {"type": "string", "contentMediaType": "image/*"},
+				"multipart/form-data": object([]string{"poster"}, "poster", jsonSchema{"type": "string", "contentMediaType": "image/*"}),
+			},
+			result: movie,
+			errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+		},
+		"GET /v1/media/*key": {
+			summary: "Download a stored file", tag: "media",
+			response: mediaResponse(),
+		},
+		"HEAD /v1/media/*key": {
+			summary: "Show the headers of a stored file", tag: "media",
+			response: map[string]interface{}{"description": "The file exists"},
+		},
+
+		"GET /v1/movies/:id/translations": {
+			summary: "List the translations of a movie", tag: "translations",
+			result: arrayOf(s.of(data.Translation{})),
+		},
+		"PUT /v1/movies/:id/translations/:locale": {
+			summary: "Create or replace a translation", tag: "translations",
+			body:   object([]string{"title"}, "title", str(), "plot", str()),
+			result: s.of(data.Translation{}),
+		},
+		"DELETE /v1/movies/:id/translations/:locale": {
+			summary: "Delete a translation", tag: "translations",
+			result: object([]string{"movie_id", "locale"}, "movie_id", integer(), "locale", str()),
+		},
+
+		"GET /v1/movies/:id/releases": {
+			summary: "List the releases of a movie", tag: "releases",
+			result: arrayOf(s.of(data.Release{})),
+		},
+		"POST /v1/movies/:id/releases": {
+			summary: "Add a release to a movie", tag: "releases",
+			body: releaseInput, status: http.StatusCreated, result: s.of(data.Release{}),
+		},
+		"PATCH /v1/movies/:id/releases/:release_id": {
+			summary: "Update a release", tag: "releases",
+			body: releaseInput, result: s.of(data.Release{}), errors: []int{http.StatusConflict},
+		},
+		"DELETE /v1/movies/:id/releases/:release_id": {
+			summary: "Delete a release", tag: "releases",
+			description: "A movie catalogued for a future year can't lose the last release dated in that year.",
+			result:      object([]string{"id"}, "id", integer()), errors: []int{http.StatusConflict},
+		},
+		"GET /v1/releases/upcoming": {
+			summary: "List the upcoming releases", tag: "releases",
+			query: append([]apiParam{
+				timeParam("from", "Releases from, today by default"),
+				{name: "region", schema: str(), description: "An ISO 3166-1 alpha-2 code"},
+				{name: "type", schema: enum(data.ReleaseTheatrical, data.ReleaseDigital, data.ReleaseFestival)},
+			}, pageParams(nil, "")...),
+			result: arrayOf(s.of(data.Release{})), paginated: true,
+		},
+
+		"GET /v1/stats/movies": {
+			summary: "Show statistics of the movies", tag: "movies",
+			description: "Statistics are computed in the background, the first request for a filter answers with accepted.",
+			query: []apiParam{
+				{name: "title", schema: str()},
+				csvParam("genres", "Same as genres_all"),
+				csvParam("genres_all", "Movies in every one of the genres"),
+				csvParam("genres_any", "Movies in any of the genres"),
+			},
+			result: s.of(data.MovieStats{}), accepted: true,
+			errors: []int{http.StatusServiceUnavailable},
+		},
+
+		"GET /v1/movies/:id/credits": {
+			summary: "List the credits of a movie", tag: "people",
+			result: arrayOf(s.of(data.Credit{})),
+		},
+		"POST /v1/movies/:id/credits": {
+			summary: "Credit a person on a movie", tag: "people",
+			body: object([]string{"person_id", "role"},
+				"person_id", integer(),
+				"role", enum(data.RoleDirector, data.RoleActor, data.RoleWriter),
+				"character_name", str(),
+				"billing_order", integer(),
+			),
+			status: http.StatusCreated, result: s.of(data.Credit{}),
+		},
+		"DELETE /v1/movies/:id/credits/:credit_id": {
+			summary: "Delete a credit", tag: "people",
+			result: object([]string{"id"}, "id", integer()),
+		},
+
+		"GET /v1/genres": {
+			summary: "List the genres", tag: "genres",
+			result: arrayOf(s.of(data.Genre{})),
+		},
+		"POST /v1/genres": {
+			summary: "Create a genre", tag: "genres",
+			body:   object(nil, "slug", str(), "name", str(), "aliases", arrayOf(str())),
+			status: http.StatusCreated, result: s.of(data.Genre{}),
+		},
+		"GET /v1/genres/:id": {
+			summary: "Show a genre", tag: "genres",
+			result: s.of(data.Genre{}),
+		},
+		"PATCH /v1/genres/:id": {
+			summary: "Update a genre", tag: "genres",
+			body:   object(nil, "slug", str(), "name", str(), "aliases", arrayOf(str())),
+			result: s.of(data.Genre{}), errors: []int{http.StatusConflict},
+		},
+		"DELETE /v1/genres/:id": {
+			summary: "Delete a genre", tag: "genres",
+			query:  []apiParam{{name: "replace_with", schema: integer(), description: "The genre which takes over the movies of the deleted one"}},
+			result: object([]string{"id"}, "id", integer()),
+		},
+
+		"GET /v1/people": {
+			summary: "List people", tag: "people",
+			query:  append([]apiParam{{name: "name", schema: str()}}, pageParams([]string{"id", "name", "-id", "-name"}, "name")...),
+			result: arrayOf(s.of(data.Person{})), paginated: true,
+		},
+		"POST /v1/people": {
+			summary: "Create a person", tag: "people",
+			body: object([]string{"name"}, "name", str()), status: http.StatusCreated, result: s.of(data.Person{}),
+		},
+		"GET /v1/people/:id": {
+			summary: "Show a person", tag: "people",
+			result: s.of(data.Person{}),
+		},
+		"PATCH /v1/people/:id": {
+			summary: "Update a person", tag: "people",
+			body: object(nil, "name", str()), result: s.of(data.Person{}), errors: []int{http.StatusConflict},
+		},
+		"DELETE /v1/people/:id": {
+			summary: "Delete a person", tag: "people",
+			result: object([]string{"id"}, "id", integer()),
+		},
+		"GET /v1/people/:id/filmography": {
+			summary: "Show a person with their credits", tag: "people",
+			result: object([]string{"person", "credits"}, "person", s.of(data.Person{}), "credits", arrayOf(s.of(data.Credit{}))),
+		},
+
+		"PUT /v1/movies/:id/rating": {
+			summary: "Rate a movie", tag: "ratings",
+			body:   object([]string{"score"}, "score", jsonSchema{"type": "integer", "minimum": 1, "maximum": 10}),
+			result: s.of(data.Rating{}),
+		},
+		"DELETE /v1/movies/:id/rating": {
+			summary: "Delete the rating of a movie", tag: "ratings",
+			result: object([]string{"movie_id"}, "movie_id", integer()),
+		},
+
+		"GET /v1/movies/:id/reviews": {
+			summary: "List the approved reviews of a movie", tag: "reviews",
+			query:  pageParams([]string{"id", "created_at", "updated_at", "-id", "-created_at", "-updated_at"}, "-created_at"),
+			result: arrayOf(s.of(data.Review{})), paginated: true,
+		},
+		"POST /v1/movies/:id/reviews": {
+			summary: "Review a movie", tag: "reviews",
+			description: "The review is pending until a moderator approves it.",
+			body:        object([]string{"title", "body"}, "title", str(), "body", str()),
+			status:      http.StatusCreated, result: s.of(data.Review{}),
+		},
+		"PATCH /v1/reviews/:id": {
+			summary: "Update a review", tag: "reviews",
+			description: "Only the author may update a review, it is pending again afterwards.",
+			body:        object(nil, "title", str(), "body", str()),
+			result:      s.of(data.Review{}), errors: []int{http.StatusConflict},
+		},
+		"GET /v1/reviews": {
+			summary: "List the reviews to moderate", tag: "reviews",
+			query: append([]apiParam{
+				{name: "status", schema: enum(data.ReviewPending, data.ReviewApproved, data.ReviewRejected)},
+			}, pageParams([]string{"id", "created_at", "updated_at", "-id", "-created_at", "-updated_at"}, "-created_at")...),
+			result: arrayOf(s.of(data.Review{})), paginated: true,
+		},
+		"POST /v1/reviews/:id/approve": {
+			summary: "Approve a review", tag: "reviews",
+			body: object(nil, "reason", str()), optionalBody: true,
+			result: s.of(data.Review{}), errors: []int{http.StatusConflict},
+		},
+		"POST /v1/reviews/:id/reject": {
+			summary: "Reject a review", tag: "reviews",
+			body: object(nil, "reason", str()), optionalBody: true,
+			result: s.of(data.Review{}), errors: []int{http.StatusConflict},
+		},
+
+		"GET /v1/collections": {
+			summary: "List the public collections", tag: "collections",
+			query: append([]apiParam{{name: "title", schema: str()}},
+				pageParams([]string{"id", "title", "created_at", "updated_at", "-id", "-title", "-created_at", "-updated_at"}, "-updated_at")...),
+			result: arrayOf(collection), paginated: true,
+		},
+		"POST /v1/collections": {
+			summary: "Create a collection", tag: "collections",
+			description: "Collections are private unless the visibility says otherwise.",
+			body:        collectionInput, status: http.StatusCreated, result: collection,
+		},
+		"GET /v1/collections/:id": {
+			summary: "Show a collection with its movies", tag: "collections",
+			description: "Private collections are only visible to their owner.",
+			result:      collection,
+		},
+		"PATCH /v1/collections/:id": {
+			summary: "Update a collection", tag: "collections",
+			body: collectionInput, result: collection, errors: []int{http.StatusConflict},
+		},
+		"DELETE /v1/collections/:id": {
+			summary: "Delete a collection", tag: "collections",
+			result: object([]string{"id"}, "id", integer()),
+		},
+		"PUT /v1/collections/:id/movies/:movie_id": {
+			summary: "Add a movie to a collection or update its note", tag: "collections",
+			body: object(nil, "note", str()), optionalBody: true,
+			result: s.of(data.CollectionMovie{}),
+		},
+		"POST /v1/collections/:id/movies/:movie_id/move": {
+			summary: "Move a movie within a collection", tag: "collections",
+			body:   object([]string{"position"}, "position", jsonSchema{"type": "integer", "minimum": 1}),
+			result: s.of(data.CollectionMovie{}),
+		},
+		"DELETE /v1/collections/:id/movies/:movie_id": {
+			summary: "Remove a movie from a collection", tag: "collections",
+			result: object([]string{"collection_id", "movie_id"}, "collection_id", integer(), "movie_id", integer()),
+		},
+
+		"POST /v1/graphql": {
+			summary: "Run a GraphQL query", tag: "graphql",
+			description: "Errors are reported in the errors of the GraphQL result, with a code in their extensions.",
+			body: object([]string{"query"},
+				"query", str(),
+				"operationName", str(),
+				"variables", jsonSchema{"type": "object"},
+			),
+			response: map[string]interface{}{
+				"description": "The GraphQL result",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": object(nil, "data", jsonSchema{}, "errors", arrayOf(jsonSchema{"type": "object"})),
+					},
+				},
+			},
+		},
+
+		"GET /v1/openapi.json": {
+			summary: "Show this document", tag: "docs",
+			response: map[string]interface{}{
+				"description": "The OpenAPI document",
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": jsonSchema{"type": "object"}}},
+			},
+		},
+		"GET /v1/docs": {
+			summary: "Show the API documentation", tag: "docs",
+			response: map[string]interface{}{
+				"description": "A page rendering this document",
+				"content":     map[string]interface{}{"text/html": map[string]interface{}{"schema": str()}},
+			},
+		},
+
+		"POST /v1/users": {
+			summary: "Register a user", tag: "users",
+			body:   object([]string{"name", "email", "password"}, "name", str(), "email", jsonSchema{"type": "string", "format": "email"}, "password", jsonSchema{"type": "string", "minLength": 8, "maxLength": 72}),
+			status: http.StatusCreated, result: s.of(data.User{}),
+		},
+		"POST /v1/tokens/authentication": {
+			summary: "Create an authentication token", tag: "users",
+			body:   object([]string{"email", "password"}, "email", jsonSchema{"type": "string", "format": "email"}, "password", str()),
+			status: http.StatusCreated, result: s.of(data.Token{}),
+			errors: []int{http.StatusUnauthorized},
+		},
+
+		"GET /v1/users/me/recommendations": {
+			summary: "Recommend movies from the ratings of the user", tag: "users",
+			description: "Answers with accepted while the first model is being trained.",
+			query:       []apiParam{limitParam(20, 100)},
+			result: arrayOf(object([]string{"movie", "score", "reason"},
+				"movie", movie,
+				"score", number(),
+				"reason", str(),
+				"because", describe(arrayOf(integer()), "The rated movies which led to the recommendation"),
+			)),
+			accepted: true,
+		},
+		"GET /v1/users/me/watchlist": {
+			summary: "List the watchlist", tag: "lists",
+			query: pageParams([]string{"added_at", "title", "year", "runtime", "rating", "-added_at", "-title", "-year", "-runtime", "-rating"}, "-added_at"), result: arrayOf(listEntry), paginated: true,
+		},
+		"PUT /v1/users/me/watchlist/:id": {
+			summary: "Add a movie to the watchlist", tag: "lists",
+			body: object(nil, "watched", boolean()), optionalBody: true, result: listEntry,
+		},
+		"PATCH /v1/users/me/watchlist/:id": {
+			summary: "Mark a movie of the watchlist as watched", tag: "lists",
+			body: object([]string{"watched"}, "watched", boolean()), result: listEntry,
+		},
+		"DELETE /v1/users/me/watchlist/:id": {
+			summary: "Remove a movie from the watchlist", tag: "lists",
+			result: object([]string{"movie_id"}, "movie_id", integer()),
+		},
+		"GET /v1/users/me/favourites": {
+			summary: "List the favourites", tag: "lists",
+			query: pageParams([]string{"added_at", "title", "year", "runtime", "rating", "-added_at", "-title", "-year", "-runtime", "-rating"}, "-added_at"), result: arrayOf(listEntry), paginated: true,
+		},
+		"PUT /v1/users/me/favourites/:id": {
+			summary: "Add a movie to the favourites", tag: "lists",
+			body: object(nil, "watched", boolean()), optionalBody: true, result: listEntry,
+		},
+		"DELETE /v1/users/me/favourites/:id": {
+			summary: "Remove a movie from the favourites", tag: "lists",
+			result: object([]string{"movie_id"}, "movie_id", integer()),
+		},
+	}
+
+	return operations
+}
+
+func mediaResponse() map[string]interface{} {
+	return map[string]interface{}{
+		"description": "The file, cacheable forever",
+		"headers": map[string]interface{}{
+			"ETag":          map[string]interface{}{"schema": str()},
+			"Last-Modified": map[string]interface{}{"schema": str()},
+		},
+		"content": map[string]interface{}{
+			"*/*": map[string]interface{}{"schema": jsonSchema{"type": "string", "contentMediaType": "application/octet-stream"}},
+		},
+	}
+}
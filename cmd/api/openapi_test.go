@@ -0,0 +1,176 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	newLogger "api.go-rifqio.my.id/internal/logger"
+	"context"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Every route needs an operation in apiOperations and every operation a route, so the
+// document can't fall behind routes.go
+func TestOpenAPICoversRoutes(t *testing.T) {
+	app := &application{}
+
+	operations := apiOperations(&schemaRegistry{schemas: map[string]jsonSchema{}})
+
+	routes := make(map[string]bool)
+	for _, r := range app.routeTable() {
+		key := operationKey(r.method, r.pattern)
+		if routes[key] {
+			t.Errorf("%s is in the route table twice", key)
+		}
+		routes[key] = true
+
+		if _, ok := operations[key]; !ok {
+			t.Errorf("%s has no operation in apiOperations", key)
+		}
+	}
+
+	for key := range operations {
+		if !routes[key] {
+			t.Errorf("the operation %s has no route", key)
+		}
+	}
+
+	// Every write needs an authenticated caller, besides signing up and logging in. GraphQL
+	// checks the permission of its mutations itself.
+	public := map[string]bool{"POST /v1/users": true, "POST /v1/tokens/authentication": true, "POST /v1/graphql": true}
+	for _, r := range app.routeTable() {
+		key := operationKey(r.method, r.pattern)
+		if r.method != http.MethodGet && r.method != http.MethodHead && r.auth == authNone && !public[key] {
+			t.Errorf("%s writes without authentication", key)
+		}
+	}
+
+	// Editing the catalogue takes movies:write
+	for _, key := range []string{"POST /v1/movies", "PATCH /v1/movies/:id", "DELETE /v1/movies/:id", "POST /v1/people", "POST /v1/movies/:id/credits"} {
+		if auth := routeAuth(app.routeTable(), key); auth != data.PermissionWriteMovies {
+			t.Errorf("%s needs %q, the route table has %q", key, data.PermissionWriteMovies, auth)
+		}
+	}
+
+	// The document takes the auth of a route from the route table, the routes have to
+	// enforce it too: an anonymous call is turned away before its request is validated
+	app.config.openapi.validate = true
+	app.logger = newLogger.New(io.Discard, newLogger.LevelInfo)
+	handler := app.routes()
+
+	for _, r := range app.routeTable() {
+		if r.auth == authNone {
+			continue
+		}
+
+		path := pathParamRX.ReplaceAllString(r.pattern, "not-an-id")
+		req := httptest.NewRequest(r.method, path+"?unknown=1", strings.NewReader("not json"))
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: an anonymous call got status %d, want %d", r.method, r.pattern, res.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+// routeAuth returns the auth of the route with the operation key
+func routeAuth(table []route, key string) string {
+	for _, r := range table {
+		if operationKey(r.method, r.pattern) == key {
+			return r.auth
+		}
+	}
+	return authNone
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	app := &application{}
+
+	spec, err := app.buildOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := json.Marshal(spec.document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every $ref has to point at a schema of the document
+	var document struct {
+		Paths      map[string]map[string]struct{ OperationID string }
+		Components struct{ Schemas map[string]json.RawMessage }
+	}
+	if err := json.Unmarshal(js, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range strings.Split(string(js), `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.IndexByte(part, '"')]
+		if _, ok := document.Components.Schemas[name]; !ok {
+			t.Errorf("$ref to the missing schema %s", name)
+		}
+	}
+
+	ids := make(map[string]string)
+	for path, methods := range document.Paths {
+		for method, op := range methods {
+			if other, ok := ids[op.OperationID]; ok {
+				t.Errorf("%s %s and %s have the same operationId %s", method, path, other, op.OperationID)
+			}
+			ids[op.OperationID] = method + " " + path
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	app := &application{}
+
+	r := route{http.MethodPut, "/v1/movies/:id/rating", authUser, nil}
+	passed := false
+	handler := app.validateRequest(r, func(http.ResponseWriter, *http.Request) { passed = true })
+
+	tests := []struct {
+		name        string
+		id          string
+		query       string
+		body        string
+		contentType string
+		status      int
+	}{
+		{"valid", "1", "", `{"score": 8}`, "application/json", http.StatusOK},
+		{"id not an integer", "abc", "", `{"score": 8}`, "application/json", http.StatusUnprocessableEntity},
+		{"unknown query parameter", "1", "?force=true", `{"score": 8}`, "application/json", http.StatusUnprocessableEntity},
+		{"score out of range", "1", "", `{"score": 11}`, "application/json", http.StatusUnprocessableEntity},
+		{"score not a number", "1", "", `{"score": "8"}`, "application/json", http.StatusUnprocessableEntity},
+		{"score missing", "1", "", `{}`, "application/json", http.StatusUnprocessableEntity},
+		{"not json", "1", "", `score=8`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"badly-formed json", "1", "", `{"score":`, "application/json", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passed = false
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/movies/"+tt.id+"/rating"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req = req.WithContext(context.WithValue(req.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: tt.id}}))
+
+			res := httptest.NewRecorder()
+			handler(res, req)
+
+			status := res.Code
+			if passed {
+				status = http.StatusOK
+			}
+			if status != tt.status {
+				t.Errorf("got status %d, want %d: %s", status, tt.status, res.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxValidatedBodyBytes bounds the JSON bodies read by validateRequest, bigger ones are left to the handler
+const maxValidatedBodyBytes = 1 << 20
+
+// validateRequest rejects requests which don't match the operation of the route in the
+// OpenAPI document before they reach the handler. It is only meant for development, so
+// clients find out about a request the document doesn't allow, like an unknown query
+// parameter, while the handlers stay as lenient as they are.
+func (app *application) validateRequest(r route, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		spec, err := app.openAPI()
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+			return
+		}
+
+		op, ok := spec.operations[operationKey(r.method, r.pattern)]
+		if !ok {
+			next(res, req)
+			return
+		}
+
+		v := &schemaValidator{schemas: spec.schemas, violations: make(map[string]string)}
+
+		params := httprouter.ParamsFromContext(req.Context())
+		for _, name := range pathParams(r.pattern) {
+			v.validateString(pathParamSchema(name), params.ByName(name), name)
+		}
+
+		v.validateQuery(op.query, req.URL.Query())
+
+		if op.body != nil && op.content == nil {
+			body, err := io.ReadAll(io.LimitReader(req.Body, maxValidatedBodyBytes+1))
+			if err != nil {
+				app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			if len(body) > 0 && len(body) <= maxValidatedBodyBytes {
+				mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+				if mediaType != "application/json" {
+					app.errorResponse(res, req, http.StatusUnsupportedMediaType, "The request body must be sent as application/json")
+					return
+				}
+
+				var value interface{}
+				err = json.Unmarshal(body, &value)
+				if err != nil {
+					app.errorResponse(res, req, http.StatusBadRequest, "Body contains badly-formed JSON")
+					return
+				}
+
+				v.validate(op.body, value, "")
+			}
+		}
+
+		if len(v.violations) > 0 {
+			app.failedValidationResponse(res, req, v.violations)
+			return
+		}
+
+		next(res, req)
+	}
+}
+
+// schemaValidator checks values against the subset of JSON Schema the document uses,
+// violations maps the path of a value to what is wrong with it
+type schemaValidator struct {
+	schemas    map[string]jsonSchema
+	violations map[string]string
+}
+
+func (v *schemaValidator) addViolation(path, message string) {
+	if _, exists := v.violations[path]; !exists {
+		v.violations[path] = fmt.Sprintf("%s %s", path, message)
+	}
+}
+
+func (v *schemaValidator) validateQuery(params []apiParam, qs map[string][]string) {
+	known := make(map[string]bool, len(params))
+
+	for _, param := range params {
+		known[param.name] = true
+
+		values, ok := qs[param.name]
+		if !ok {
+			if param.required {
+				v.addViolation(param.name, "must be provided")
+			}
+			continue
+		}
+
+		v.validateString(param.schema, values[0], param.name)
+	}
+
+	for name := range qs {
+		if !known[name] {
+			v.addViolation(name, "is not a parameter of this route")
+		}
+	}
+}
+
+// validateString converts a path or query value to the type of the schema before it is validated
+func (v *schemaValidator) validateString(schema jsonSchema, raw, path string) {
+	var value interface{} = raw
+
+	switch schema["type"] {
+	case "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			v.addViolation(path, "must be an integer")
+			return
+		}
+		value = float64(n)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			v.addViolation(path, "must be a boolean")
+			return
+		}
+		value = b
+	}
+
+	v.validate(schema, value, path)
+}
+
+// validate checks a value decoded from JSON against the schema
+func (v *schemaValidator) validate(schema jsonSchema, value interface{}, path string) {
+	if name, ok := schema["$ref"].(string); ok {
+		referenced, ok := v.schemas[strings.TrimPrefix(name, "#/components/schemas/")]
+		if ok {
+			v.validate(referenced, value, path)
+		}
+		return
+	}
+
+	if alternatives, ok := schema["anyOf"].([]jsonSchema); ok {
+		for _, alternative := range alternatives {
+			check := &schemaValidator{schemas: v.schemas, violations: make(map[string]string)}
+			if check.validate(alternative, value, path); len(check.violations) == 0 {
+				return
+			}
+		}
+		v.addViolation(v.name(path), "doesn't match any of the allowed schemas")
+		return
+	}
+
+	if !v.validateType(schema["type"], value, path) {
+		return
+	}
+
+	if values, ok := schema["enum"].([]string); ok {
+		s, _ := value.(string)
+		found := false
+		for _, allowed := range values {
+			found = found || s == allowed
+		}
+		if !found {
+			v.addViolation(v.name(path), "must be one of "+strings.Join(values, ", "))
+			return
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		if n, ok := schema["minLength"].(int); ok && len(value) < n {
+			v.addViolation(v.name(path), fmt.Sprintf("must be at least %d bytes long", n))
+		}
+		if n, ok := schema["maxLength"].(int); ok && len(value) > n {
+			v.addViolation(v.name(path), fmt.Sprintf("must not be more than %d bytes long", n))
+		}
+		if schema["format"] == "date" {
+			if _, err := time.Parse(time.DateOnly, value); err != nil {
+				v.addViolation(v.name(path), "must be a date like 2006-01-02")
+			}
+		}
+
+	case float64:
+		if n, ok := schema["minimum"].(int); ok && value < float64(n) {
+			v.addViolation(v.name(path), fmt.Sprintf("must be at least %d", n))
+		}
+		if n, ok := schema["maximum"].(int); ok && value > float64(n) {
+			v.addViolation(v.name(path), fmt.Sprintf("must not be more than %d", n))
+		}
+
+	case []interface{}:
+		if items, ok := schema["items"].(jsonSchema); ok {
+			for i, item := range value {
+				v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, ok := value[name]; !ok {
+					v.addViolation(v.join(path, name), "must be provided")
+				}
+			}
+		}
+
+		properties, _ := schema["properties"].(jsonSchema)
+		additional, _ := schema["additionalProperties"].(jsonSchema)
+
+		for name, field := range value {
+			if property, ok := properties[name].(jsonSchema); ok {
+				v.validate(property, field, v.join(path, name))
+			} else if additional != nil {
+				v.validate(additional, field, v.join(path, name))
+			}
+		}
+	}
+}
+
+// validateType reports whether the value is of one of the types the schema allows
+func (v *schemaValidator) validateType(types interface{}, value interface{}, path string) bool {
+	var allowed []string
+	switch types := types.(type) {
+	case string:
+		allowed = []string{types}
+	case []string:
+		allowed = types
+	default:
+		return true
+	}
+
+	for _, t := range allowed {
+		if jsonType(value, t) {
+			return true
+		}
+	}
+
+	v.addViolation(v.name(path), "must be "+article(strings.Join(allowed, " or ")))
+	return false
+}
+
+func jsonType(value interface{}, t string) bool {
+	switch value := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && value == math.Trunc(value))
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+func article(noun string) string {
+	switch noun[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an " + noun
+	}
+	return "a " + noun
+}
+
+func (v *schemaValidator) join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// name is the key of a violation of the whole body
+func (v *schemaValidator) name(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) createPersonHandler(res http.ResponseWriter, req *http.Request) {
+	type CreatePersonDTO struct {
+		Name string `json:"name"`
+	}
+
+	body := new(CreatePersonDTO)
+
+	err := app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	person := &data.Person{Name: body.Name}
+
+	validate := validator.New()
+
+	if data.ValidatePerson(validate, person); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.People.Insert(person)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/people/%d", person.ID))
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = person
+	response.Message = "Person Created Successfully"
+
+	err = app.writeJSON(res, http.StatusCreated, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// showPeopleHandler searches people by name
+func (app *application) showPeopleHandler(res http.ResponseWriter, req *http.Request) {
+	var filters data.Filters
+
+	validate := validator.New()
+	qs := req.URL.Query()
+
+	name := app.readString(qs, "name", "")
+	validate.Check(len(name) <= 255, "name", "name max length is 255 characters")
+
+	filters.Page = app.readInt(qs, "page", 1, validate)
+	filters.PageSize = app.readInt(qs, "page_size", 10, validate)
+	filters.Cursor = app.readString(qs, "cursor", "")
+	filters.IncludeTotal = app.readBool(qs, "include_total", true, validate)
+
+	filters.Sort = app.readString(qs, "sort", "name")
+	filters.SortSafeList = []string{"id", "name", "-id", "-name"}
+
+	if data.ValidateFilters(validate, &filters); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	people, paginationMetadata, err := app.models.People.GetAll(name, filters)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = people
+	response.Message = "People Fetched Successfully"
+	response.Pagination = &paginationMetadata
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) showPersonHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = person
+	response.Message = "Person Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// showFilmographyHandler lists every movie a person is credited on, newest first
+func (app *application) showFilmographyHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	credits, err := app.models.Credits.GetFilmography(person.ID)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"person": person, "credits": credits}
+	response.Message = "Filmography Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// updatePersonHandler renames a person, the movies they are credited on follow the new name
+func (app *application) updatePersonHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type UpdatePersonDTO struct {
+		Name *string `json:"name"`
+	}
+
+	body := new(UpdatePersonDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	if body.Name != nil {
+		person.Name = *body.Name
+	}
+
+	validate := validator.New()
+
+	if data.ValidatePerson(validate, person); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.People.Update(person)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = person
+	response.Message = "Person Updated Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deletePersonHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	err = app.models.People.Delete(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"id": id}
+	response.Message = fmt.Sprintf("Person With The Following ID %d has Been Deleted", id)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/plotindex"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+type plotMatch struct {
+	Movie *data.Movie `json:"movie"`
+	// Score is the cosine similarity of the plots, between 0 and 1
+	Score float64 `json:"score"`
+}
+
+// buildPlotIndex loads the snapshot of the plot index and catches up with the movies changed
+// since, so only the plots of those movies are read. Without a snapshot every plot is read.
+func (app *application) buildPlotIndex() error {
+	start := time.Now()
+
+	err := app.plotIndex.Load(app.config.plotIndex.snapshot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// A broken snapshot is rebuilt from scratch
+		app.logger.PrintError(err, map[string]string{"snapshot": app.config.plotIndex.snapshot})
+	}
+
+	versions, err := app.models.Movie.GetPlotVersions()
+	if err != nil {
+		return err
+	}
+
+	var changed []int64
+	indexed := app.plotIndex.Versions()
+
+	for id := range indexed {
+		if _, ok := versions[id]; !ok {
+			app.plotIndex.Remove(id)
+		}
+	}
+
+	for id, version := range versions {
+		if v, ok := indexed[id]; !ok || v != version {
+			changed = append(changed, id)
+		}
+	}
+
+	for len(changed) > 0 {
+		batch := changed
+		if len(batch) > 500 {
+			batch = batch[:500]
+		}
+		changed = changed[len(batch):]
+
+		movies, err := app.models.Movie.GetMany(batch)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			app.plotIndex.Put(movie.ID, movie.Version, movie.Plot)
+		}
+	}
+
+	documents, dropped := app.plotIndex.Len()
+	app.logger.PrintInfo("plot index built", map[string]string{
+		"documents": strconv.Itoa(documents),
+		"dropped":   strconv.Itoa(dropped),
+		"duration":  time.Since(start).String(),
+	})
+
+	return app.plotIndex.Save(app.config.plotIndex.snapshot)
+}
+
+// snapshotPlotIndexPeriodically saves the plot index once per interval when it changed,
+// and one last time when stop is closed
+func (app *application) snapshotPlotIndexPeriodically(stop <-chan struct{}) {
+	ticker := time.NewTicker(app.config.plotIndex.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+		case <-ticker.C:
+		}
+
+		err := app.plotIndex.Save(app.config.plotIndex.snapshot)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"snapshot": app.config.plotIndex.snapshot})
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// plotMatches loads the matched movies, movies deleted in the meantime are left out
+func (app *application) plotMatches(matches []plotindex.Match) ([]*plotMatch, error) {
+	ids := make([]int64, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+
+	movies, err := app.models.Movie.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*data.Movie, len(movies))
+	for _, movie := range movies {
+		byID[movie.ID] = movie
+	}
+
+	result := []*plotMatch{}
+	for _, match := range matches {
+		if movie, ok := byID[match.ID]; ok {
+			result = append(result, &plotMatch{Movie: movie, Score: match.Score})
+		}
+	}
+
+	return result, nil
+}
+
+// showSimilarPlotMoviesHandler lists the movies whose plot is closest to the plot of the movie in the path
+func (app *application) showSimilarPlotMoviesHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	validate := validator.New()
+
+	limit := app.readInt(req.URL.Query(), "limit", 10, validate)
+
+	validate.Check(limit > 0, "limit", "limit is invalid")
+	validate.Check(limit <= 50, "limit", "limit exceed maximum")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	_, err = app.models.Movie.GetFields(id, []string{"id"})
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	result, err := app.plotMatches(app.plotIndex.Similar(id, limit))
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = result
+	response.Message = "Movies With A Similar Plot Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// discoverMoviesHandler ranks the movies by how close their plot is to free text, like ?about=heist gone wrong
+func (app *application) discoverMoviesHandler(res http.ResponseWriter, req *http.Request) {
+	qs := req.URL.Query()
+
+	validate := validator.New()
+
+	about := app.readString(qs, "about", "")
+	limit := app.readInt(qs, "limit", 10, validate)
+
+	validate.Check(about != "", "about", "about must be provided")
+	validate.Check(len(about) <= 500, "about", "about max length is 500 characters")
+	validate.Check(limit > 0, "limit", "limit is invalid")
+	validate.Check(limit <= 50, "limit", "limit exceed maximum")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	result, err := app.plotMatches(app.plotIndex.Search(about, limit))
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = result
+	response.Message = "Movies Discovered Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/images"
+	"api.go-rifqio.my.id/internal/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// uploadPosterHandler stores a poster sent either as the "poster" field of a multipart
+// form or as the raw request body. The image type is sniffed from the content and
+// the metadata is stripped before the poster is written to storage.
+func (app *application) uploadPosterHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(res, req.Body, app.config.posters.maxBytes)
+
+	body, err := app.readPoster(req)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			message := fmt.Sprintf("The poster must not be larger than %d bytes", app.config.posters.maxBytes)
+			app.errorResponse(res, req, http.StatusRequestEntityTooLarge, message)
+			return
+		}
+		if errors.Is(err, images.ErrTooManyPixels) {
+			message := fmt.Sprintf("The poster must not have more than %d pixels", app.config.posters.maxPixels)
+			app.failedValidationResponse(res, req, map[string]string{"poster": message})
+			return
+		}
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType, err := images.Sniff(body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusUnsupportedMediaType, "The poster must be a JPEG, PNG or WebP image")
+		return
+	}
+
+	body, err = images.StripMetadata(body, contentType)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, "The poster image is malformed")
+		return
+	}
+
+	// Keys are derived from the content so a stored poster never changes and can be cached forever
+	key := fmt.Sprintf("posters/%d/%x%s", movie.ID, sha256.Sum256(body), images.Extensions[contentType])
+
+	err = app.storage.Put(req.Context(), key, bytes.NewReader(body), contentType)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	previous := movie.PosterKey
+
+	err = app.models.Movie.SetPoster(movie, key, app.storage.URL(key))
+	if err != nil {
+		if key != previous {
+			app.deletePoster(key)
+		}
+
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	if previous != "" && previous != key {
+		app.deletePoster(previous)
+	}
+
+	// The scaled down sizes show up in poster_srcset once they are ready
+	poster := data.PosterRef{MovieID: movie.ID, Key: key}
+	app.background(func() {
+		err := app.generatePosterSizes(poster)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"key": poster.Key})
+		}
+	})
+
+	response := data.NewResponse()
+	response.Result = movie
+	response.Message = "Poster Uploaded Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// readPoster reads the whole poster into memory, the body is already limited to the max poster size.
+// Only the image header is looked at to reject posters with too many pixels.
+func (app *application) readPoster(req *http.Request) ([]byte, error) {
+	body, err := app.readPosterBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Anything which isn't a supported image is reported once its type is sniffed
+	err = images.CheckPixels(body, app.config.posters.maxPixels)
+	if errors.Is(err, images.ErrTooManyPixels) {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (app *application) readPosterBody(req *http.Request) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 {
+			return nil, errors.New("Body must not be empty")
+		}
+		return body, nil
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("Form must contain a poster file")
+			}
+			return nil, err
+		}
+
+		if part.FormName() == "poster" {
+			return io.ReadAll(part)
+		}
+	}
+}
+
+// posterSizes are the widths derived from every uploaded poster, named like the srcset keys
+var posterSizes = []int{92, 185, 500}
+
+// posterSizeKey is the storage key of a derived size, next to the poster it is derived from
+func posterSizeKey(key string, width int) string {
+	return fmt.Sprintf("%s-w%d.jpg", strings.TrimSuffix(key, path.Ext(key)), width)
+}
+
+// generatePosterSizes scales an uploaded poster down to every poster size
+// and stores their URLs as the poster_srcset of the movie
+func (app *application) generatePosterSizes(poster data.PosterRef) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	object, err := app.storage.Get(ctx, poster.Key)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	body, err := io.ReadAll(object.Body)
+	if err != nil {
+		return err
+	}
+
+	// Checked again since the poster may predate the pixel limit or the limit may have been lowered
+	img, err := images.Decode(body, app.config.posters.maxPixels)
+	if err != nil {
+		return err
+	}
+
+	srcset := data.PosterSrcset{}
+
+	for _, width := range posterSizes {
+		scaled, err := images.Resize(img, width)
+		if err != nil {
+			return err
+		}
+
+		key := posterSizeKey(poster.Key, width)
+
+		err = app.storage.Put(ctx, key, bytes.NewReader(scaled), images.JPEG)
+		if err != nil {
+			return err
+		}
+
+		srcset[fmt.Sprintf("w%d", width)] = app.storage.URL(key)
+	}
+
+	return app.models.Movie.SetPosterSrcset(poster, srcset)
+}
+
+// regeneratePosterSizes derives the poster sizes of every movie again, for when the sizes
+// change or derivatives went missing. It keeps going past failing posters.
+func (app *application) regeneratePosterSizes() error {
+	posters, err := app.models.Movie.GetAllPosters()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+
+	for _, poster := range posters {
+		err := app.generatePosterSizes(poster)
+		if err != nil {
+			failed++
+			app.logger.PrintError(err, map[string]string{
+				"movie_id": fmt.Sprint(poster.MovieID),
+				"key":      poster.Key,
+			})
+		}
+	}
+
+	app.logger.PrintInfo("regenerated poster sizes", map[string]string{
+		"posters": fmt.Sprint(len(posters)),
+		"failed":  fmt.Sprint(failed),
+	})
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d posters failed", failed, len(posters))
+	}
+
+	return nil
+}
+
+// deletePoster removes a poster which is no longer referenced, failures only leave an orphan file behind
+func (app *application) deletePoster(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keys := []string{key}
+	for _, width := range posterSizes {
+		keys = append(keys, posterSizeKey(key, width))
+	}
+
+	for _, key := range keys {
+		err := app.storage.Delete(ctx, key)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"key": key})
+		}
+	}
+}
+
+// showMediaHandler serves a file from the local storage. Stored keys change with their
+// content, so the response is cacheable forever and revalidation only compares the key.
+func (app *application) showMediaHandler(res http.ResponseWriter, req *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(req.Context()).ByName("key"), "/")
+
+	object, err := app.storage.Get(req.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+	defer object.Body.Close()
+
+	etag := fmt.Sprintf(`"%s"`, strings.ReplaceAll(key, "/", "-"))
+
+	res.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	res.Header().Set("ETag", etag)
+	res.Header().Set("Last-Modified", object.ModTime.UTC().Format(http.TimeFormat))
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if req.Header.Get("If-None-Match") == etag {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	res.Header().Set("Content-Type", object.ContentType)
+	res.Header().Set("Content-Length", fmt.Sprint(object.Size))
+	res.WriteHeader(http.StatusOK)
+
+	if req.Method == http.MethodHead {
+		return
+	}
+
+	_, err = io.Copy(res, object.Body)
+	if err != nil {
+		app.logError(req, err)
+	}
+}
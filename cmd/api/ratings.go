@@ -0,0 +1,90 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) rateMovieHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type RateMovieDTO struct {
+		Score int32 `json:"score"`
+	}
+
+	body := new(RateMovieDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rating := &data.Rating{
+		UserID:  app.contextGetUser(req).ID,
+		MovieID: id,
+		Score:   body.Score,
+	}
+
+	validate := validator.New()
+
+	if data.ValidateRating(validate, rating); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Rating.Upsert(rating)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = rating
+	response.Message = "Movie Rated Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deleteMovieRatingHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	err = app.models.Rating.Delete(app.contextGetUser(req).ID, id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"movie_id": id}
+	response.Message = fmt.Sprintf("Rating of Movie With The Following ID %d has Been Deleted", id)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/recommend"
+	"api.go-rifqio.my.id/internal/validator"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recommender holds the latest trained model, requests read it while the training swaps it
+type recommender struct {
+	mu      sync.RWMutex
+	model   *recommend.Model
+	trained time.Time
+}
+
+func (r *recommender) get() (*recommend.Model, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.model, r.trained
+}
+
+func (r *recommender) set(model *recommend.Model) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.model = model
+	r.trained = time.Now()
+}
+
+func (app *application) showRecommendationsHandler(res http.ResponseWriter, req *http.Request) {
+	type RecommendationResult struct {
+		Movie  *data.Movie `json:"movie"`
+		Score  float64     `json:"score"`
+		Reason string      `json:"reason"`
+		// Because holds the ids of the rated movies which led to the recommendation
+		Because []int64 `json:"because,omitempty"`
+	}
+
+	validate := validator.New()
+
+	limit := app.readInt(req.URL.Query(), "limit", 20, validate)
+
+	validate.Check(limit > 0, "limit", "limit is invalid")
+	validate.Check(limit <= 100, "limit", "limit exceed maximum")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	model, trained := app.recommender.get()
+	if model == nil {
+		headers := make(http.Header)
+		headers.Set("Retry-After", "5")
+
+		response := data.NewResponse()
+		response.Message = "Recommendations Are Being Computed, Please Retry Shortly"
+		response.StatusCode = http.StatusAccepted
+
+		err := app.writeJSON(res, http.StatusAccepted, response, headers)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	// The ratings are read fresh so a new rating counts before the next training
+	ratings, err := app.models.Rating.GetForUser(app.contextGetUser(req).ID)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	scores := make(map[int64]float64, len(ratings))
+	for _, rating := range ratings {
+		scores[rating.MovieID] = float64(rating.Score)
+	}
+
+	recommendations := model.Recommend(scores, limit)
+
+	ids := make([]int64, len(recommendations))
+	for i, r := range recommendations {
+		ids[i] = r.MovieID
+	}
+
+	// Movies deleted since the training are left out
+	movies, err := app.models.Movie.GetMany(ids)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	byID := make(map[int64]*data.Movie, len(movies))
+	for _, movie := range movies {
+		byID[movie.ID] = movie
+	}
+
+	result := []*RecommendationResult{}
+	for _, r := range recommendations {
+		movie, ok := byID[r.MovieID]
+		if !ok {
+			continue
+		}
+		result = append(result, &RecommendationResult{
+			Movie:   movie,
+			Score:   r.Score,
+			Reason:  r.Reason,
+			Because: r.Because,
+		})
+	}
+
+	headers := make(http.Header)
+	headers.Set("Last-Modified", trained.UTC().Format(http.TimeFormat))
+
+	response := data.NewResponse()
+	response.Result = result
+	response.Message = "Recommendations Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// trainRecommender rebuilds the item-item similarities from every rating
+func (app *application) trainRecommender() error {
+	ratings, err := app.models.Rating.GetAll()
+	if err != nil {
+		return err
+	}
+
+	training := make([]recommend.Rating, len(ratings))
+	for i, rating := range ratings {
+		training[i] = recommend.Rating{
+			UserID:  rating.UserID,
+			MovieID: rating.MovieID,
+			Score:   float64(rating.Score),
+		}
+	}
+
+	options := recommend.DefaultOptions
+	options.Neighbours = app.config.recommend.neighbours
+
+	start := time.Now()
+	app.recommender.set(recommend.Train(training, options))
+
+	app.logger.PrintInfo("recommendations trained", map[string]string{
+		"ratings":  strconv.Itoa(len(training)),
+		"duration": time.Since(start).String(),
+	})
+
+	return nil
+}
+
+// trainRecommenderPeriodically trains the recommendations right away and then once per
+// interval until stop is closed
+func (app *application) trainRecommenderPeriodically(stop <-chan struct{}) {
+	ticker := time.NewTicker(app.config.recommend.interval)
+	defer ticker.Stop()
+
+	for {
+		err := app.trainRecommender()
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"job": "recommendations"})
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (app *application) showMovieReleasesHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	releases, err := app.models.Releases.GetForMovie(movie.ID)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = releases
+	response.Message = "Releases Fetched Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// showUpcomingReleasesHandler lists releases from today on, or from ?from=, soonest first
+func (app *application) showUpcomingReleasesHandler(res http.ResponseWriter, req *http.Request) {
+	var filters data.Filters
+
+	validate := validator.New()
+	qs := req.URL.Query()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := app.readTime(qs, "from", today, validate)
+
+	region := strings.ToUpper(app.readString(qs, "region", ""))
+	validate.Check(region == "" || data.CountryRX.MatchString(region), "region", "region must be an ISO 3166-1 alpha-2 code like US")
+
+	releaseType := app.readString(qs, "type", "")
+	validate.Check(releaseType == "" || validator.In(releaseType, data.ReleaseTheatrical, data.ReleaseDigital, data.ReleaseFestival), "type", "type must be theatrical, digital or festival")
+
+	filters.Page = app.readInt(qs, "page", 1, validate)
+	filters.PageSize = app.readInt(qs, "page_size", 10, validate)
+	filters.Cursor = app.readString(qs, "cursor", "")
+	filters.IncludeTotal = app.readBool(qs, "include_total", true, validate)
+
+	// Upcoming releases only make sense soonest first
+	filters.Sort = "release_date"
+	filters.SortSafeList = []string{"release_date"}
+
+	if data.ValidateFilters(validate, &filters); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	releases, paginationMetadata, err := app.models.Releases.Upcoming(from, region, releaseType, filters)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = releases
+	response.Message = "Upcoming Releases Fetched Successfully"
+	response.Pagination = &paginationMetadata
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) createMovieReleaseHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type CreateReleaseDTO struct {
+		Country       string    `json:"country"`
+		ReleaseDate   data.Date `json:"release_date"`
+		ReleaseType   string    `json:"release_type"`
+		Certification string    `json:"certification"`
+	}
+
+	body := new(CreateReleaseDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	release := &data.Release{
+		MovieID:       id,
+		Country:       strings.ToUpper(body.Country),
+		ReleaseDate:   body.ReleaseDate,
+		ReleaseType:   body.ReleaseType,
+		Certification: body.Certification,
+	}
+
+	validate := validator.New()
+
+	if data.ValidateRelease(validate, release); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Releases.Insert(release)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRelease):
+			validate.AddError("release_type", "the movie already has a release of this type in this country")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrReleaseYear):
+			validate.AddError("release_date", "the movie is catalogued for a later year, its first release has to be dated in that year or the year of the movie changed first")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrNoRecordsFound):
+			app.redirectMergedMovie(res, req, id)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/movies/%d/releases", release.MovieID))
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = release
+	response.Message = "Release Created Successfully"
+
+	err = app.writeJSON(res, http.StatusCreated, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) updateMovieReleaseHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	releaseID, err := app.readNamedIDParam(req, "release_id")
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type UpdateReleaseDTO struct {
+		Country       *string    `json:"country"`
+		ReleaseDate   *data.Date `json:"release_date"`
+		ReleaseType   *string    `json:"release_type"`
+		Certification *string    `json:"certification"`
+	}
+
+	body := new(UpdateReleaseDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	release, err := app.models.Releases.Get(id, releaseID)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	if body.Country != nil {
+		release.Country = strings.ToUpper(*body.Country)
+	}
+
+	if body.ReleaseDate != nil {
+		release.ReleaseDate = *body.ReleaseDate
+	}
+
+	if body.ReleaseType != nil {
+		release.ReleaseType = *body.ReleaseType
+	}
+
+	if body.Certification != nil {
+		release.Certification = *body.Certification
+	}
+
+	validate := validator.New()
+
+	if data.ValidateRelease(validate, release); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Releases.Update(release)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRelease):
+			validate.AddError("release_type", "the movie already has a release of this type in this country")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrReleaseYear):
+			validate.AddError("release_date", "the movie is catalogued for the year of this release, change the year of the movie first")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(res, req)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = release
+	response.Message = "Release Updated Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deleteMovieReleaseHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	releaseID, err := app.readNamedIDParam(req, "release_id")
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	err = app.models.Releases.Delete(id, releaseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordsFound):
+			app.redirectMergedMovie(res, req, id)
+		case errors.Is(err, data.ErrReleaseYear):
+			app.errorResponse(res, req, http.StatusConflict, "The movie is catalogued for the year of this release, change the year of the movie first")
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"id": releaseID}
+	response.Message = fmt.Sprintf("Release With The Following ID %d has Been Deleted", releaseID)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) createReviewHandler(res http.ResponseWriter, req *http.Request) {
+	movieID, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type CreateReviewDTO struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+
+	body := new(CreateReviewDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	review := &data.Review{
+		MovieID: movieID,
+		UserID:  app.contextGetUser(req).ID,
+		Title:   body.Title,
+		Body:    body.Body,
+	}
+
+	validate := validator.New()
+
+	if data.ValidateReview(validate, review); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReview):
+			validate.AddError("movie_id", "you have already reviewed this movie, edit your review instead")
+			app.failedValidationResponse(res, req, validate.Errors)
+		case errors.Is(err, data.ErrNoRecordsFound):
+			app.redirectMergedMovie(res, req, movieID)
+		default:
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/reviews/%d", review.ID))
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = review
+	response.Message = "Review Submitted For Moderation"
+
+	err = app.writeJSON(res, http.StatusCreated, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// showMovieReviewsHandler lists the approved reviews of a movie, it is public
+func (app *application) showMovieReviewsHandler(res http.ResponseWriter, req *http.Request) {
+	movieID, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	app.listReviews(res, req, movieID, data.ReviewApproved)
+}
+
+// showReviewQueueHandler lists reviews by status for moderators, pending by default
+func (app *application) showReviewQueueHandler(res http.ResponseWriter, req *http.Request) {
+	validate := validator.New()
+
+	status := app.readString(req.URL.Query(), "status", data.ReviewPending)
+	validate.Check(validator.In(status, data.ReviewPending, data.ReviewApproved, data.ReviewRejected), "status", "Invalid Status Value")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	app.listReviews(res, req, 0, status)
+}
+
+func (app *application) listReviews(res http.ResponseWriter, req *http.Request, movieID int64, status string) {
+	var filters data.Filters
+
+	validate := validator.New()
+	qs := req.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, validate)
+	filters.PageSize = app.readInt(qs, "page_size", 10, validate)
+	filters.Cursor = app.readString(qs, "cursor", "")
+	filters.IncludeTotal = app.readBool(qs, "include_total", true, validate)
+
+	filters.Sort = app.readString(qs, "sort", "-created_at")
+	filters.SortSafeList = []string{"id", "created_at", "updated_at", "-id", "-created_at", "-updated_at"}
+
+	if data.ValidateFilters(validate, &filters); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	reviews, paginationMetadata, err := app.models.Reviews.GetAll(movieID, status, filters)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	// The reviews of a merged movie moved along with it
+	if movieID != 0 && len(reviews) == 0 && app.redirectIfMerged(res, req, movieID) {
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = reviews
+	response.Message = "Reviews Fetched Successfully"
+	response.Pagination = &paginationMetadata
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// updateReviewHandler lets the author edit their own review, which sends it back to moderation
+func (app *application) updateReviewHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type UpdateReviewDTO struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+	}
+
+	body := new(UpdateReviewDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	review, err := app.models.Reviews.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	// Only the author may edit, anybody else is told the review doesn't exist
+	if review.UserID != app.contextGetUser(req).ID {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	if body.Title != nil {
+		review.Title = *body.Title
+	}
+
+	if body.Body != nil {
+		review.Body = *body.Body
+	}
+
+	validate := validator.New()
+
+	if data.ValidateReview(validate, review); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = review
+	response.Message = "Review Updated And Submitted For Moderation"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) approveReviewHandler(res http.ResponseWriter, req *http.Request) {
+	app.decideReview(res, req, data.ReviewApproved)
+}
+
+func (app *application) rejectReviewHandler(res http.ResponseWriter, req *http.Request) {
+	app.decideReview(res, req, data.ReviewRejected)
+}
+
+// decideReview stores the decision of a moderator and emails the author about it
+func (app *application) decideReview(res http.ResponseWriter, req *http.Request, status string) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type DecideReviewDTO struct {
+		Reason string `json:"reason"`
+	}
+
+	body := new(DecideReviewDTO)
+
+	// The reason is optional for an approval, so an empty body is fine
+	if req.ContentLength != 0 {
+		err = app.readJSON(res, req, &body)
+		if err != nil {
+			app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	review, err := app.models.Reviews.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.notFoundResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	validate := validator.New()
+
+	if data.ValidateReviewDecision(validate, review, status, body.Reason); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Decide(review, status, body.Reason, app.contextGetUser(req).ID)
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			app.editConflictResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	app.background(func() {
+		author, err := app.models.User.Get(review.UserID)
+		if err != nil {
+			app.logError(req, err)
+			return
+		}
+
+		movie, err := app.models.Movie.Get(review.MovieID)
+		if err != nil {
+			app.logError(req, err)
+			return
+		}
+
+		dataEmail := map[string]interface{}{
+			"name":        author.Name,
+			"movieTitle":  movie.Title,
+			"reviewTitle": review.Title,
+			"status":      review.Status,
+			"reason":      review.ModerationReason,
+		}
+
+		err = app.mailer.Send(author.Email, "review_decision.tmpl", dataEmail)
+		if err != nil {
+			app.logError(req, err)
+		}
+	})
+
+	response := data.NewResponse()
+	response.Result = review
+	response.Message = fmt.Sprintf("Review Has Been %s", map[string]string{
+		data.ReviewApproved: "Approved",
+		data.ReviewRejected: "Rejected",
+	}[status])
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
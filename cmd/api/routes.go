@@ -1,26 +1,211 @@
 package main
 
 import (
+	"api.go-rifqio.my.id/internal/data"
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 	"net/http"
+	"strings"
 )
 
+// route is one entry of the route table, the OpenAPI document needs an operation for each
+type route struct {
+	method  string
+	pattern string
+	// auth is authNone for public routes, authUser when any authenticated user may call
+	// the route, otherwise the permission code the user needs
+	auth    string
+	handler http.HandlerFunc
+}
+
+const (
+	authNone = ""
+	authUser = "user"
+)
+
+// routeTable lists every route of the API
+func (app *application) routeTable() []route {
+	return []route{
+		{http.MethodGet, "/v1/healthcheck", authNone, app.healthCheckHandler},
+		{http.MethodGet, "/v1/movies", authNone, app.showMoviesHandler},
+		{http.MethodPost, "/v1/movies", data.PermissionWriteMovies, app.createMovieHandler},
+		{http.MethodGet, "/v1/movies/autocomplete", authNone, app.autocompleteMoviesHandler},
+		{http.MethodGet, "/v1/movies/lookup", authNone, app.lookupMovieHandler},
+		{http.MethodGet, "/v1/movies/discover", authNone, app.discoverMoviesHandler},
+		{http.MethodGet, "/v1/movies/:id", authNone, app.showMovieHandler},
+		{http.MethodPatch, "/v1/movies/:id", data.PermissionWriteMovies, app.updateMovieHandler},
+		{http.MethodPut, "/v1/external/:source/:external_id", data.PermissionWriteMovies, app.upsertExternalMovieHandler},
+		{http.MethodDelete, "/v1/movies/:id", data.PermissionWriteMovies, app.deleteMovieHandler},
+		{http.MethodPost, "/v1/movies/:id/merge", data.PermissionMergeMovies, app.mergeMovieHandler},
+
+		{http.MethodGet, "/v1/movies/:id/similar", authNone, app.showSimilarMoviesHandler},
+		{http.MethodGet, "/v1/movies/:id/similar-plot", authNone, app.showSimilarPlotMoviesHandler},
+
+		{http.MethodPut, "/v1/movies/:id/poster", data.PermissionWriteMovies, app.uploadPosterHandler},
+		{http.MethodGet, "/v1/media/*key", authNone, app.showMediaHandler},
+		{http.MethodHead, "/v1/media/*key", authNone, app.showMediaHandler},
+
+		{http.MethodGet, "/v1/movies/:id/translations", authNone, app.showMovieTranslationsHandler},
+		{http.MethodPut, "/v1/movies/:id/translations/:locale", data.PermissionWriteMovies, app.putMovieTranslationHandler},
+		{http.MethodDelete, "/v1/movies/:id/translations/:locale", data.PermissionWriteMovies, app.deleteMovieTranslationHandler},
+
+		{http.MethodGet, "/v1/movies/:id/releases", authNone, app.showMovieReleasesHandler},
+		{http.MethodPost, "/v1/movies/:id/releases", data.PermissionWriteMovies, app.createMovieReleaseHandler},
+		{http.MethodPatch, "/v1/movies/:id/releases/:release_id", data.PermissionWriteMovies, app.updateMovieReleaseHandler},
+		{http.MethodDelete, "/v1/movies/:id/releases/:release_id", data.PermissionWriteMovies, app.deleteMovieReleaseHandler},
+		{http.MethodGet, "/v1/releases/upcoming", authNone, app.showUpcomingReleasesHandler},
+
+		{http.MethodGet, "/v1/stats/movies", authNone, app.showMovieStatsHandler},
+
+		{http.MethodGet, "/v1/movies/:id/credits", authNone, app.showMovieCreditsHandler},
+		{http.MethodPost, "/v1/movies/:id/credits", data.PermissionWriteMovies, app.createMovieCreditHandler},
+		{http.MethodDelete, "/v1/movies/:id/credits/:credit_id", data.PermissionWriteMovies, app.deleteMovieCreditHandler},
+
+		{http.MethodGet, "/v1/genres", authNone, app.showGenresHandler},
+		{http.MethodPost, "/v1/genres", data.PermissionManageGenres, app.createGenreHandler},
+		{http.MethodGet, "/v1/genres/:id", authNone, app.showGenreHandler},
+		{http.MethodPatch, "/v1/genres/:id", data.PermissionManageGenres, app.updateGenreHandler},
+		{http.MethodDelete, "/v1/genres/:id", data.PermissionManageGenres, app.deleteGenreHandler},
+
+		{http.MethodGet, "/v1/people", authNone, app.showPeopleHandler},
+		{http.MethodPost, "/v1/people", data.PermissionWriteMovies, app.createPersonHandler},
+		{http.MethodGet, "/v1/people/:id", authNone, app.showPersonHandler},
+		{http.MethodPatch, "/v1/people/:id", data.PermissionWriteMovies, app.updatePersonHandler},
+		{http.MethodDelete, "/v1/people/:id", data.PermissionWriteMovies, app.deletePersonHandler},
+		{http.MethodGet, "/v1/people/:id/filmography", authNone, app.showFilmographyHandler},
+
+		{http.MethodPut, "/v1/movies/:id/rating", authUser, app.rateMovieHandler},
+		{http.MethodDelete, "/v1/movies/:id/rating", authUser, app.deleteMovieRatingHandler},
+
+		{http.MethodGet, "/v1/movies/:id/reviews", authNone, app.showMovieReviewsHandler},
+		{http.MethodPost, "/v1/movies/:id/reviews", authUser, app.createReviewHandler},
+		{http.MethodPatch, "/v1/reviews/:id", authUser, app.updateReviewHandler},
+		{http.MethodGet, "/v1/reviews", data.PermissionModerateReviews, app.showReviewQueueHandler},
+		{http.MethodPost, "/v1/reviews/:id/approve", data.PermissionModerateReviews, app.approveReviewHandler},
+		{http.MethodPost, "/v1/reviews/:id/reject", data.PermissionModerateReviews, app.rejectReviewHandler},
+
+		{http.MethodGet, "/v1/collections", authNone, app.showCollectionsHandler},
+		{http.MethodPost, "/v1/collections", authUser, app.createCollectionHandler},
+		{http.MethodGet, "/v1/collections/:id", authNone, app.showCollectionHandler},
+		{http.MethodPatch, "/v1/collections/:id", authUser, app.updateCollectionHandler},
+		{http.MethodDelete, "/v1/collections/:id", authUser, app.deleteCollectionHandler},
+		{http.MethodPut, "/v1/collections/:id/movies/:movie_id", authUser, app.putCollectionMovieHandler},
+		{http.MethodPost, "/v1/collections/:id/movies/:movie_id/move", authUser, app.moveCollectionMovieHandler},
+		{http.MethodDelete, "/v1/collections/:id/movies/:movie_id", authUser, app.deleteCollectionMovieHandler},
+
+		{http.MethodPost, "/v1/graphql", authNone, app.graphqlHandler()},
+
+		{http.MethodGet, "/v1/openapi.json", authNone, app.showOpenAPIHandler},
+		{http.MethodGet, "/v1/docs", authNone, app.showDocsHandler},
+
+		{http.MethodPost, "/v1/users", authNone, app.registerUserHandler},
+		{http.MethodPost, "/v1/tokens/authentication", authNone, app.createAuthenticationTokenHandler},
+
+		{http.MethodGet, "/v1/users/me/recommendations", authUser, app.showRecommendationsHandler},
+		{http.MethodGet, "/v1/users/me/watchlist", authUser, app.showListHandler(data.ListWatchlist)},
+		{http.MethodPut, "/v1/users/me/watchlist/:id", authUser, app.addToListHandler(data.ListWatchlist)},
+		{http.MethodPatch, "/v1/users/me/watchlist/:id", authUser, app.updateListEntryHandler(data.ListWatchlist)},
+		{http.MethodDelete, "/v1/users/me/watchlist/:id", authUser, app.removeFromListHandler(data.ListWatchlist)},
+		{http.MethodGet, "/v1/users/me/favourites", authUser, app.showListHandler(data.ListFavourites)},
+		{http.MethodPut, "/v1/users/me/favourites/:id", authUser, app.addToListHandler(data.ListFavourites)},
+		{http.MethodDelete, "/v1/users/me/favourites/:id", authUser, app.removeFromListHandler(data.ListFavourites)},
+	}
+}
+
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthCheckHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies", app.showMoviesHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
-	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.updateMovieHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	table := app.routeTable()
+
+	// Authentication wraps the validation, so an anonymous call is answered with 401
+	// or 403 before anything about its request is looked at
+	handlers := make([]http.HandlerFunc, len(table))
+	for i, r := range table {
+		handlers[i] = r.handler
+		if app.config.openapi.validate {
+			handlers[i] = app.validateRequest(r, handlers[i])
+		}
+		handlers[i] = app.requireAuth(r.auth, handlers[i])
+	}
+
+	// httprouter doesn't allow a static segment next to a wildcard, so routes like
+	// /v1/movies/autocomplete are dispatched from the /v1/movies/:id route instead
+	named := make(map[int]map[string]http.HandlerFunc)
+	params := make(map[int]string)
+	shadowed := make(map[int]bool)
+
+	for i, r := range table {
+		for j, w := range table {
+			param, segment, ok := staticNextToWildcard(r, w)
+			if !ok {
+				continue
+			}
+			if named[j] == nil {
+				named[j] = make(map[string]http.HandlerFunc)
+			}
+			named[j][segment] = handlers[i]
+			params[j] = param
+			shadowed[i] = true
+		}
+	}
+
+	for i, r := range table {
+		if shadowed[i] {
+			continue
+		}
+
+		handler := handlers[i]
+		if named[i] != nil {
+			handler = app.staticSegments(params[i], named[i], handler)
+		}
+
+		router.HandlerFunc(r.method, r.pattern, handler)
+	}
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	//return app.recoverPanic(app.rateLimiter(router))
-	standard := alice.New(app.requestLogger, app.rateLimiter, app.recoverPanic)
+	standard := alice.New(app.requestLogger, app.rateLimiter, app.recoverPanic, app.authenticate)
 	return standard.Then(router)
 }
+
+// staticNextToWildcard reports whether the pattern of r only differs from the pattern of w
+// in one segment which is static in r and a :param in w, it returns the param and the segment
+func staticNextToWildcard(r, w route) (string, string, bool) {
+	if r.method != w.method {
+		return "", "", false
+	}
+
+	rs := strings.Split(r.pattern, "/")
+	ws := strings.Split(w.pattern, "/")
+	if len(rs) != len(ws) {
+		return "", "", false
+	}
+
+	param, segment := "", ""
+	for i := range rs {
+		if rs[i] == ws[i] {
+			continue
+		}
+		if param != "" || !strings.HasPrefix(ws[i], ":") || strings.HasPrefix(rs[i], ":") {
+			return "", "", false
+		}
+		param, segment = strings.TrimPrefix(ws[i], ":"), rs[i]
+	}
+
+	return param, segment, param != ""
+}
+
+// staticSegments serves the handler registered for the value of param when there is one,
+// otherwise the request is passed on to next
+func (app *application) staticSegments(param string, handlers map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		params := httprouter.ParamsFromContext(req.Context())
+		if handler, ok := handlers[params.ByName(param)]; ok {
+			handler(res, req)
+			return
+		}
+		next(res, req)
+	}
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -21,8 +24,43 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// The gRPC server runs next to the HTTP server and stops with it. Its listener is
+	// opened first, so a taken address fails before any background job has started.
+	var grpcSrv *grpc.Server
+	var grpcHealth *health.Server
+
+	if app.config.grpc.addr != "" {
+		listener, err := net.Listen("tcp", app.config.grpc.addr)
+		if err != nil {
+			return err
+		}
+
+		grpcSrv, grpcHealth = app.grpcServer()
+
+		go func() {
+			app.logger.PrintInfo("Starting gRPC server on", map[string]string{"addr": app.config.grpc.addr})
+			err := grpcSrv.Serve(listener)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"addr": app.config.grpc.addr})
+			}
+		}()
+	}
+
 	shutdownError := make(chan error)
 
+	// Stops the jobs which run for as long as the server does
+	stop := make(chan struct{})
+
+	app.background(func() {
+		app.refreshStatsPeriodically(stop)
+	})
+	app.background(func() {
+		app.trainRecommenderPeriodically(stop)
+	})
+	app.background(func() {
+		app.snapshotPlotIndexPeriodically(stop)
+	})
+
 	go func() {
 		// Create a quit channel which carries os.Signal value
 		quit := make(chan os.Signal, 1)
@@ -42,10 +80,16 @@ func (app *application) serve() error {
 			shutdownError <- err
 		}
 
+		if grpcSrv != nil {
+			grpcHealth.Shutdown()
+			stopGRPC(ctx, grpcSrv)
+		}
+
 		app.logger.PrintInfo("completing background task", map[string]string{
 			"addr": srv.Addr,
 		})
 
+		close(stop)
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
@@ -68,3 +112,19 @@ func (app *application) serve() error {
 
 	return nil
 }
+
+// stopGRPC lets the running calls finish and cuts them off once ctx is done
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"net/http"
+)
+
+// showSimilarMoviesHandler lists the movies most like the one in the path, "more like this"
+func (app *application) showSimilarMoviesHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	validate := validator.New()
+
+	limit := app.readInt(req.URL.Query(), "limit", app.config.similar.limit, validate)
+
+	validate.Check(limit > 0, "limit", "limit is invalid")
+	validate.Check(limit <= 50, "limit", "limit exceed maximum")
+
+	if !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	weights := data.SimilarityWeights{
+		Genres:     app.config.similar.genres,
+		Actors:     app.config.similar.actors,
+		Director:   app.config.similar.director,
+		Year:       app.config.similar.year,
+		YearWindow: app.config.similar.yearWindow,
+	}
+
+	similar, err := app.models.Movie.Similar(movie, weights, limit)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = similar
+	response.Message = "Similar Movies Retrieved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsMaxEntries bounds the number of filter combinations kept in the stats cache
+const statsMaxEntries = 100
+
+type statsEntry struct {
+	query         data.MovieQuery
+	stats         *data.MovieStats
+	refreshing    bool
+	lastRequested time.Time
+}
+
+// statsCache holds the catalogue statistics per filter. Entries are only ever computed in the
+// background, a request either gets the cached stats or schedules the first computation.
+// refreshes is a semaphore bounding the computations running at the same time, since every
+// one of them aggregates the whole table.
+type statsCache struct {
+	mu        sync.Mutex
+	entries   map[string]*statsEntry
+	refreshes chan struct{}
+}
+
+func newStatsCache(maxRefreshes int) *statsCache {
+	return &statsCache{
+		entries:   make(map[string]*statsEntry),
+		refreshes: make(chan struct{}, maxRefreshes),
+	}
+}
+
+// statsKey identifies a filter regardless of the case and order it was written in
+func statsKey(query data.MovieQuery) string {
+	genresAll := append([]string{}, query.GenresAll...)
+	genresAny := append([]string{}, query.GenresAny...)
+	sort.Strings(genresAll)
+	sort.Strings(genresAny)
+
+	return strings.Join([]string{
+		strings.ToLower(strings.TrimSpace(query.Title)),
+		strings.ToLower(strings.Join(genresAll, ",")),
+		strings.ToLower(strings.Join(genresAny, ",")),
+	}, "|")
+}
+
+func (app *application) showMovieStatsHandler(res http.ResponseWriter, req *http.Request) {
+	var query data.MovieQuery
+
+	validate := validator.New()
+
+	qs := req.URL.Query()
+
+	query.Title = app.readString(qs, "title", "")
+	// genres is kept for existing clients and behaves like genres_all
+	query.GenresAll = app.readCSV(qs, "genres_all", app.readCSV(qs, "genres", []string{}))
+	query.GenresAny = app.readCSV(qs, "genres_any", []string{})
+
+	if data.ValidateMovieQuery(validate, &query); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	key := statsKey(query)
+
+	app.stats.mu.Lock()
+	entry, ok := app.stats.entries[key]
+	if !ok {
+		entry = &statsEntry{query: query}
+		app.stats.evict()
+		app.stats.entries[key] = entry
+	}
+	entry.lastRequested = time.Now()
+	stats := entry.stats
+
+	// A filter already being computed isn't computed twice, a new one has to wait
+	// for a free slot when the max number of computations is running
+	start, busy := false, false
+	if stats == nil && !entry.refreshing {
+		select {
+		case app.stats.refreshes <- struct{}{}:
+			start = true
+			entry.refreshing = true
+		default:
+			busy = true
+			if !ok {
+				delete(app.stats.entries, key)
+			}
+		}
+	}
+	app.stats.mu.Unlock()
+
+	if busy {
+		app.serverBusyResponse(res, req, 2)
+		return
+	}
+
+	if start {
+		app.background(func() {
+			app.refreshStats(key, query)
+		})
+	}
+
+	// The first request for a filter doesn't wait for the aggregates
+	if stats == nil {
+		headers := make(http.Header)
+		headers.Set("Retry-After", "2")
+
+		response := data.NewResponse()
+		response.Message = "Movie Statistics Are Being Computed, Please Retry Shortly"
+		response.StatusCode = http.StatusAccepted
+
+		err := app.writeJSON(res, http.StatusAccepted, response, headers)
+		if err != nil {
+			app.internalServerErrorResponse(res, req, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Age", strconv.Itoa(int(time.Since(stats.GeneratedAt).Seconds())))
+
+	response := data.NewResponse()
+	response.Result = stats
+	response.Message = "Movie Statistics Retrieved Successfully"
+
+	err := app.writeJSON(res, 200, response, headers)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// evict drops the least recently requested filter once the cache is full, the
+// unfiltered stats are always kept. The caller holds the lock.
+func (c *statsCache) evict() {
+	if len(c.entries) < statsMaxEntries {
+		return
+	}
+
+	var oldest string
+	for key, entry := range c.entries {
+		if key == statsKey(data.MovieQuery{}) {
+			continue
+		}
+		if oldest == "" || entry.lastRequested.Before(c.entries[oldest].lastRequested) {
+			oldest = key
+		}
+	}
+
+	delete(c.entries, oldest)
+}
+
+// refreshStats recomputes the stats of one filter and stores them in the cache, the caller
+// holds a slot of the refreshes semaphore which is released once the stats are stored
+func (app *application) refreshStats(key string, query data.MovieQuery) {
+	defer func() { <-app.stats.refreshes }()
+
+	stats, err := app.models.Movie.Stats(context.Background(), query, app.config.stats.top)
+
+	app.stats.mu.Lock()
+	defer app.stats.mu.Unlock()
+
+	entry, ok := app.stats.entries[key]
+	if !ok {
+		return
+	}
+	entry.refreshing = false
+
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"stats": key})
+		return
+	}
+	entry.stats = stats
+}
+
+// refreshStatsPeriodically warms the unfiltered stats and then refreshes every cached filter once
+// per TTL until stop is closed. Filters nobody asked for during the last few TTLs are dropped.
+func (app *application) refreshStatsPeriodically(stop <-chan struct{}) {
+	ttl := app.config.stats.ttl
+
+	app.stats.mu.Lock()
+	app.stats.entries[statsKey(data.MovieQuery{})] = &statsEntry{refreshing: true, lastRequested: time.Now()}
+	app.stats.mu.Unlock()
+
+	app.stats.refreshes <- struct{}{}
+	app.refreshStats(statsKey(data.MovieQuery{}), data.MovieQuery{})
+
+	ticker := time.NewTicker(ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		type pending struct {
+			key   string
+			query data.MovieQuery
+		}
+		var refresh []pending
+
+		app.stats.mu.Lock()
+		for key, entry := range app.stats.entries {
+			if key != statsKey(data.MovieQuery{}) && time.Since(entry.lastRequested) > 3*ttl {
+				delete(app.stats.entries, key)
+				continue
+			}
+			if !entry.refreshing {
+				entry.refreshing = true
+				refresh = append(refresh, pending{key, entry.query})
+			}
+		}
+		app.stats.mu.Unlock()
+
+		for _, p := range refresh {
+			// Waits for a slot, requests for new filters may hold all of them
+			select {
+			case <-stop:
+				return
+			case app.stats.refreshes <- struct{}{}:
+			}
+			app.refreshStats(p.key, p.query)
+		}
+	}
+}
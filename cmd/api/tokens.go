@@ -0,0 +1,72 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"net/http"
+	"time"
+)
+
+func (app *application) createAuthenticationTokenHandler(res http.ResponseWriter, req *http.Request) {
+	type CreateAuthenticationTokenDTO struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	body := new(CreateAuthenticationTokenDTO)
+
+	err := app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, body.Email)
+	data.ValidatePasswordPlaintext(v, body.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(res, req, v.Errors)
+		return
+	}
+
+	user, err := app.models.User.GetByEmail(body.Email)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.invalidCredentialsResponse(res, req)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	match, err := user.Password.Matches(body.Password)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	if !match {
+		app.invalidCredentialsResponse(res, req)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.StatusCode = http.StatusCreated
+	response.Result = token
+	response.Message = "Authentication Token Created Successfully"
+
+	err = app.writeJSON(res, http.StatusCreated, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
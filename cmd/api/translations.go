@@ -0,0 +1,136 @@
+package main
+
+import (
+	"api.go-rifqio.my.id/internal/data"
+	"api.go-rifqio.my.id/internal/validator"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+func (app *application) showMovieTranslationsHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	movie, err := app.models.Movie.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	translations, err := app.models.Translations.GetAll(movie.ID)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = translations
+	response.Message = "Translations Fetched Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+// putMovieTranslationHandler creates or replaces the translation of a movie for the locale in the path
+func (app *application) putMovieTranslationHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	type PutTranslationDTO struct {
+		Title string `json:"title"`
+		Plot  string `json:"plot"`
+	}
+
+	body := new(PutTranslationDTO)
+
+	err = app.readJSON(res, req, &body)
+	if err != nil {
+		app.errorResponse(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	translation := &data.Translation{
+		MovieID: id,
+		Locale:  httprouter.ParamsFromContext(req.Context()).ByName("locale"),
+		Title:   body.Title,
+		Plot:    body.Plot,
+	}
+
+	validate := validator.New()
+
+	if data.ValidateTranslation(validate, translation); !validate.Valid() {
+		app.failedValidationResponse(res, req, validate.Errors)
+		return
+	}
+
+	translation.Locale, _ = data.CanonicalLocale(translation.Locale)
+
+	err = app.models.Translations.Upsert(translation)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = translation
+	response.Message = "Translation Saved Successfully"
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
+
+func (app *application) deleteMovieTranslationHandler(res http.ResponseWriter, req *http.Request) {
+	id, err := app.readIDParam(req)
+	if err != nil {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	locale, ok := data.CanonicalLocale(httprouter.ParamsFromContext(req.Context()).ByName("locale"))
+	if !ok {
+		app.notFoundResponse(res, req)
+		return
+	}
+
+	err = app.models.Translations.Delete(id, locale)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			app.redirectMergedMovie(res, req, id)
+			return
+		}
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+
+	response := data.NewResponse()
+	response.Result = envelope{"movie_id": id, "locale": locale}
+	response.Message = fmt.Sprintf("Translation %s of Movie With The Following ID %d has Been Deleted", locale, id)
+
+	err = app.writeJSON(res, 200, response, nil)
+	if err != nil {
+		app.internalServerErrorResponse(res, req, err)
+		return
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"api.go-rifqio.my.id/internal/data"
 	"api.go-rifqio.my.id/internal/validator"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -66,10 +68,10 @@ func (app *application) registerUserHandler(res http.ResponseWriter, req *http.R
 			"activationToken": token.PlainText,
 			"userID":          user.ID,
 		}
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", dataEmail)
+		// The response has already been sent by now, so the error can only be logged
+		err := app.mailer.Send(user.Email, "user_welcome.tmpl", dataEmail)
 		if err != nil {
-			app.internalServerErrorResponse(res, req, err)
-			return
+			app.logError(req, err)
 		}
 	})
 
@@ -84,3 +86,39 @@ func (app *application) registerUserHandler(res http.ResponseWriter, req *http.R
 		return
 	}
 }
+
+// grantPermissions grants permission codes to the user with an email, given as
+// email=code,code. Nothing grants permissions over the API, this is how a user gets them.
+func (app *application) grantPermissions(grant string) error {
+	email, list, ok := strings.Cut(grant, "=")
+	if !ok || email == "" || list == "" {
+		return errors.New("grant must be formatted as email=code,code")
+	}
+
+	codes := strings.Split(list, ",")
+	for _, code := range codes {
+		if !validator.In(code, data.PermissionCodes...) {
+			return fmt.Errorf("unknown permission %q, it must be one of %s", code, strings.Join(data.PermissionCodes, ", "))
+		}
+	}
+
+	user, err := app.models.User.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRecordsFound) {
+			return fmt.Errorf("no user with the email %s", email)
+		}
+		return err
+	}
+
+	err = app.models.Permissions.AddForUser(user.ID, codes...)
+	if err != nil {
+		return err
+	}
+
+	app.logger.PrintInfo("granted permissions", map[string]string{
+		"user_id":     fmt.Sprint(user.ID),
+		"permissions": list,
+	})
+
+	return nil
+}
@@ -0,0 +1,483 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strconv"
+	"time"
+)
+
+const (
+	VisibilityPublic   = "public"
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+)
+
+var (
+	ErrDuplicateCollectionMovie = errors.New("movie already in collection")
+)
+
+type Collection struct {
+	ID          int64              `json:"id"`
+	UserID      int64              `json:"user_id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Visibility  string             `json:"visibility"`
+	Movies      []*CollectionMovie `json:"movies,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Version     int32              `json:"version"`
+}
+
+// CollectionMovie is a movie in a collection together with its place and note
+type CollectionMovie struct {
+	Position int32     `json:"position"`
+	Note     string    `json:"note"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie"`
+}
+
+// VisibleTo check whether a user may see the collection, unlisted collections
+// are visible to anybody who knows the id, they just aren't searchable
+func (c *Collection) VisibleTo(user *User) bool {
+	return c.Visibility != VisibilityPrivate || c.UserID == user.ID
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+const collectionColumns = `id, user_id, title, description, visibility, created_at, updated_at, version`
+
+func (c *Collection) collectionDest() []interface{} {
+	return []interface{}{
+		&c.ID,
+		&c.UserID,
+		&c.Title,
+		&c.Description,
+		&c.Visibility,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.Version,
+	}
+}
+
+func (m *CollectionModel) Insert(collection *Collection) error {
+	query := `insert into collections (user_id, title, description, visibility)
+			  values ($1, $2, $3, $4)
+			  returning id, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{collection.UserID, collection.Title, collection.Description, collection.Visibility}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&collection.ID,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+		&collection.Version,
+	)
+}
+
+func (m *CollectionModel) Get(id int64) (*Collection, error) {
+	if id < 1 {
+		return nil, ErrNoRecordsFound
+	}
+
+	query := fmt.Sprintf(`select %s from collections where id = $1`, collectionColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var collection Collection
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(collection.collectionDest()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// GetMovies returns the movies of a collection in their position order
+func (m *CollectionModel) GetMovies(collectionID int64) ([]*CollectionMovie, error) {
+	columns, _ := new(Movie).movieColumns(nil)
+
+	query := fmt.Sprintf(`select collection_movies.position, collection_movies.note, collection_movies.added_at, %s
+						  from collection_movies inner join movies on movies.id = collection_movies.movie_id
+						  where collection_movies.collection_id = $1
+						  order by collection_movies.position`, columns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*CollectionMovie{}
+
+	for rows.Next() {
+		entry := CollectionMovie{Movie: &Movie{}}
+
+		_, dest := entry.Movie.movieColumns(nil)
+		err := rows.Scan(append([]interface{}{&entry.Position, &entry.Note, &entry.AddedAt}, dest...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetAllPublic searches the public collections by title, private and unlisted ones are never listed
+func (m *CollectionModel) GetAllPublic(title string, filter Filters) ([]*Collection, PaginationMetadata, error) {
+	q := &queryBuilder{}
+
+	q.where("visibility = 'public'")
+
+	if title != "" {
+		q.where(fmt.Sprintf("to_tsvector('simple', title) @@ plainto_tsquery('simple', %s)", q.arg(title)))
+	}
+
+	countQuery := q.clone()
+
+	sortColumn := filter.sortColumn()
+	filter.keyset(q, sortColumn)
+
+	totalColumn := "0"
+	if filter.IncludeTotal && filter.cursor == nil {
+		totalColumn = "count(*) over()"
+	}
+
+	pagination := fmt.Sprintf("limit %s", q.arg(filter.limit()+1))
+	if filter.cursor == nil {
+		pagination += fmt.Sprintf(" offset %s", q.arg(filter.offset()))
+	}
+
+	query := fmt.Sprintf(`select %s, %s from collections %s %s %s`,
+		totalColumn, collectionColumns, q.whereClause(), filter.orderBy(sortColumn), pagination)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	collections := []*Collection{}
+
+	for rows.Next() {
+		var collection Collection
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, collection.collectionDest()...)...)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+
+		collections = append(collections, &collection)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+
+	collections, hasMore := trimPage(filter, collections)
+
+	if filter.IncludeTotal && filter.cursor != nil {
+		query := fmt.Sprintf(`select count(*) from collections %s`, countQuery.whereClause())
+		err = m.DB.QueryRowContext(ctx, query, countQuery.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+	}
+
+	first, last := pageBoundaries(collections, func(collection *Collection) cursor {
+		return cursor{Value: collection.sortValue(sortColumn), ID: collection.ID}
+	})
+
+	return collections, filter.paginationMetadata(totalRecords, hasMore, first, last), nil
+}
+
+func (c *Collection) sortValue(column string) string {
+	switch column {
+	case "title":
+		return c.Title
+	case "created_at":
+		return c.CreatedAt.Format(time.RFC3339)
+	case "updated_at":
+		return c.UpdatedAt.Format(time.RFC3339)
+	default:
+		return strconv.FormatInt(c.ID, 10)
+	}
+}
+
+// Update saves the collection details using the same optimistic locking as MovieModel.Update
+func (m *CollectionModel) Update(collection *Collection) error {
+	query := `update collections set title = $1, description = $2, visibility = $3,
+			  updated_at = now(), version = version + 1
+			  where id = $4 and version = $5
+			  returning updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{
+		collection.Title,
+		collection.Description,
+		collection.Visibility,
+		collection.ID,
+		collection.Version,
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&collection.UpdatedAt, &collection.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *CollectionModel) Delete(id int64) error {
+	query := `delete from collections where id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+// withCollectionLock runs fn in a transaction holding the row lock of the collection,
+// which serializes every change to the positions of its movies
+func (m *CollectionModel) withCollectionLock(ctx context.Context, collectionID int64, fn func(tx *sql.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Adding, moving or removing a movie counts as a change of the collection
+	_, err = tx.ExecContext(ctx, `update collections set updated_at = now() where id = $1`, collectionID)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// renumberCollections closes up the gaps left in the positions of the collections
+// once movies were taken out of them
+func renumberCollections(ctx context.Context, tx *sql.Tx, collections []int64) error {
+	if len(collections) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`update collection_movies set position = ranked.position
+		 from (
+			 select collection_id, movie_id, row_number() over (partition by collection_id order by position) as position
+			 from collection_movies where collection_id = any($1)
+		 ) as ranked
+		 where collection_movies.collection_id = ranked.collection_id and collection_movies.movie_id = ranked.movie_id
+		 and collection_movies.position <> ranked.position`,
+		pq.Array(collections),
+	)
+
+	return err
+}
+
+// AddMovie appends a movie at the end of the collection
+func (m *CollectionModel) AddMovie(collectionID, movieID int64, note string) (*CollectionMovie, error) {
+	query := `insert into collection_movies (collection_id, movie_id, position, note)
+			  values ($1, $2, (select coalesce(max(position), 0) + 1 from collection_movies where collection_id = $1), $3)
+			  returning position, note, added_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := &CollectionMovie{Movie: &Movie{ID: movieID}}
+
+	err := m.withCollectionLock(ctx, collectionID, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query, collectionID, movieID, note).Scan(&entry.Position, &entry.Note, &entry.AddedAt)
+	})
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return nil, ErrDuplicateCollectionMovie
+			case "23503":
+				return nil, ErrNoRecordsFound
+			}
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// UpdateMovieNote replaces the note of a movie in the collection
+func (m *CollectionModel) UpdateMovieNote(collectionID, movieID int64, note string) (*CollectionMovie, error) {
+	query := `update collection_movies set note = $3
+			  where collection_id = $1 and movie_id = $2
+			  returning position, note, added_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := &CollectionMovie{Movie: &Movie{ID: movieID}}
+
+	err := m.DB.QueryRowContext(ctx, query, collectionID, movieID, note).Scan(&entry.Position, &entry.Note, &entry.AddedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// RemoveMovie takes a movie out of the collection and closes the gap it leaves behind
+func (m *CollectionModel) RemoveMovie(collectionID, movieID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.withCollectionLock(ctx, collectionID, func(tx *sql.Tx) error {
+		var position int32
+
+		err := tx.QueryRowContext(ctx,
+			`delete from collection_movies where collection_id = $1 and movie_id = $2 returning position`,
+			collectionID, movieID,
+		).Scan(&position)
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecordsFound
+			}
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`update collection_movies set position = position - 1 where collection_id = $1 and position > $2`,
+			collectionID, position,
+		)
+		return err
+	})
+}
+
+// MoveMovie moves a movie to a new position, shifting the movies in between by one.
+// A position past the end of the collection moves the movie to the end.
+func (m *CollectionModel) MoveMovie(collectionID, movieID int64, position int32) (*CollectionMovie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := &CollectionMovie{Movie: &Movie{ID: movieID}}
+
+	err := m.withCollectionLock(ctx, collectionID, func(tx *sql.Tx) error {
+		var current, last int32
+
+		err := tx.QueryRowContext(ctx,
+			`select position, (select max(position) from collection_movies where collection_id = $1)
+			 from collection_movies where collection_id = $1 and movie_id = $2`,
+			collectionID, movieID,
+		).Scan(&current, &last)
+
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecordsFound
+			}
+			return err
+		}
+
+		if position > last {
+			position = last
+		}
+
+		// Moving up pushes the movies in between down, moving down pulls them up
+		shift := `update collection_movies set position = position + 1
+				  where collection_id = $1 and position >= $2 and position < $3`
+		args := []interface{}{collectionID, position, current}
+
+		if position > current {
+			shift = `update collection_movies set position = position - 1
+					 where collection_id = $1 and position > $2 and position <= $3`
+			args = []interface{}{collectionID, current, position}
+		}
+
+		if position != current {
+			_, err = tx.ExecContext(ctx, shift, args...)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRowContext(ctx,
+			`update collection_movies set position = $3 where collection_id = $1 and movie_id = $2
+			 returning position, note, added_at`,
+			collectionID, movieID, position,
+		).Scan(&entry.Position, &entry.Note, &entry.AddedAt)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func ValidateCollection(v *validator.Validator, collection *Collection) {
+	v.Check(collection.Title != "", "title", "title must be provided")
+	v.Check(len(collection.Title) <= 100, "title", "title max length is 100 characters")
+
+	v.Check(len(collection.Description) <= 2000, "description", "description max length is 2000 characters")
+
+	v.Check(validator.In(collection.Visibility, VisibilityPublic, VisibilityPrivate, VisibilityUnlisted),
+		"visibility", "visibility must be public, private or unlisted")
+}
+
+func ValidateCollectionNote(v *validator.Validator, note string) {
+	v.Check(len(note) <= 1000, "note", "note max length is 1000 characters")
+}
@@ -0,0 +1,263 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	RoleDirector = "director"
+	RoleActor    = "actor"
+	RoleWriter   = "writer"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("duplicate credit")
+)
+
+// Credit links a person to a movie. Depending on the listing either
+// the person or the movie is filled in.
+type Credit struct {
+	ID            int64   `json:"id"`
+	MovieID       int64   `json:"movie_id"`
+	PersonID      int64   `json:"person_id"`
+	Role          string  `json:"role"`
+	CharacterName string  `json:"character_name,omitempty"`
+	BillingOrder  int32   `json:"billing_order"`
+	Person        *Person `json:"person,omitempty"`
+	Movie         *Movie  `json:"movie,omitempty"`
+}
+
+// CreditModel wraps the credits table. Every change to a credit rebuilds the
+// director and actors columns of the movie through a trigger.
+type CreditModel struct {
+	DB *sql.DB
+}
+
+func (m *CreditModel) Insert(credit *Credit) error {
+	query := `insert into credits (movie_id, person_id, role, character_name, billing_order)
+			  values ($1, $2, $3, $4, $5)
+			  returning id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{credit.MovieID, credit.PersonID, credit.Role, credit.CharacterName, credit.BillingOrder}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return ErrDuplicateCredit
+			case "23503":
+				return ErrNoRecordsFound
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetForMovie returns the cast and crew of a movie in billing order
+func (m *CreditModel) GetForMovie(movieID int64) ([]*Credit, error) {
+	query := `select credits.id, credits.movie_id, credits.person_id, credits.role, credits.character_name,
+			  credits.billing_order, people.name
+			  from credits inner join people on people.id = credits.person_id
+			  where credits.movie_id = $1
+			  order by credits.role, credits.billing_order, credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.scanCredits(ctx, query, movieID)
+}
+
+// GetForMovies returns the cast and crew of several movies at once, grouped by
+// movie and in billing order within a movie
+func (m *CreditModel) GetForMovies(movieIDs []int64) ([]*Credit, error) {
+	query := `select credits.id, credits.movie_id, credits.person_id, credits.role, credits.character_name,
+			  credits.billing_order, people.name
+			  from credits inner join people on people.id = credits.person_id
+			  where credits.movie_id = any($1)
+			  order by credits.movie_id, credits.role, credits.billing_order, credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.scanCredits(ctx, query, pq.Array(movieIDs))
+}
+
+func (m *CreditModel) scanCredits(ctx context.Context, query string, args ...interface{}) ([]*Credit, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		credit := Credit{Person: &Person{}}
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.Role,
+			&credit.CharacterName,
+			&credit.BillingOrder,
+			&credit.Person.Name,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credit.Person.ID = credit.PersonID
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// GetFilmography returns every credit of a person with a summary of the movie, newest first
+func (m *CreditModel) GetFilmography(personID int64) ([]*Credit, error) {
+	query := `select credits.id, credits.movie_id, credits.person_id, credits.role, credits.character_name,
+			  credits.billing_order, movies.title, movies.year, movies.poster_url
+			  from credits inner join movies on movies.id = credits.movie_id
+			  where credits.person_id = $1
+			  order by movies.year desc, movies.title, credits.role`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		credit := Credit{Movie: &Movie{}}
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.Role,
+			&credit.CharacterName,
+			&credit.BillingOrder,
+			&credit.Movie.Title,
+			&credit.Movie.Year,
+			&credit.Movie.PosterURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credit.Movie.ID = credit.MovieID
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+func (m *CreditModel) Delete(movieID, creditID int64) error {
+	query := `delete from credits where id = $1 and movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, creditID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+// syncMovieCredits makes the director and actor credits of a movie match the free text
+// director and actors, matching people by name and creating the ones that don't exist yet.
+// Writer credits and the character names of people who stay credited are left alone.
+func syncMovieCredits(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	names := append([]string{movie.Director}, movie.Actors...)
+
+	_, err := tx.ExecContext(ctx,
+		`insert into people (name)
+		 select distinct on (lower(named.name)) named.name from unnest($1::text[]) as named(name)
+		 where named.name <> '' and not exists (select 1 from people where lower(people.name) = lower(named.name))
+		 order by lower(named.name), named.name`,
+		pq.Array(names),
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `with wanted as (
+				  select distinct on (person.id, named.role) person.id as person_id, named.role, named.billing_order
+				  from (
+					  select $2::text as name, 'director' as role, 0::bigint as billing_order where $2 <> ''
+					  union all
+					  select actor.name, 'actor', actor.billing_order
+					  from unnest($3::text[]) with ordinality as actor(name, billing_order)
+				  ) as named
+				  cross join lateral (
+					  select id from people where lower(people.name) = lower(named.name) order by id limit 1
+				  ) as person
+				  order by person.id, named.role, named.billing_order
+			  ), removed as (
+				  delete from credits
+				  where credits.movie_id = $1 and credits.role in ('director', 'actor')
+				  and not exists (
+					  select 1 from wanted where wanted.person_id = credits.person_id and wanted.role = credits.role
+				  )
+			  )
+			  insert into credits (movie_id, person_id, role, billing_order)
+			  select $1, person_id, role, billing_order from wanted
+			  on conflict (movie_id, person_id, role) do update set billing_order = excluded.billing_order`
+
+	_, err = tx.ExecContext(ctx, query, movie.ID, movie.Director, pq.Array(movie.Actors))
+	if err != nil {
+		return err
+	}
+
+	// The trigger on credits rebuilt the columns from the matched people,
+	// read them back since the spelling of an existing person wins
+	return tx.QueryRowContext(ctx, `select director, actors from movies where id = $1`, movie.ID).Scan(
+		&movie.Director,
+		pq.Array(&movie.Actors),
+	)
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "person_id must be provided")
+	v.Check(validator.In(credit.Role, RoleDirector, RoleActor, RoleWriter), "role", "role must be director, actor or writer")
+	v.Check(len(credit.CharacterName) <= 255, "character_name", "character_name max length is 255 characters")
+	v.Check(credit.Role == RoleActor || credit.CharacterName == "", "character_name", "only actors play a character")
+	v.Check(credit.BillingOrder >= 0, "billing_order", "billing_order is invalid")
+}
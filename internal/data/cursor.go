@@ -0,0 +1,43 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the decoded form of the opaque pagination cursor. It holds the sort
+// key and id of the row at the page boundary, so the next query can continue
+// with a keyset predicate instead of an OFFSET.
+type cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int64  `json:"i"`
+	Backward bool   `json:"b,omitempty"`
+	// Fuzzy is set on the cursors of a search which fell back to trigram matching
+	Fuzzy bool `json:"f,omitempty"`
+}
+
+// encodeCursor turns a cursor into a URL safe string
+func encodeCursor(c cursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// decodeCursor reverses encodeCursor, any malformed input results in ErrInvalidCursor
+func decodeCursor(value string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
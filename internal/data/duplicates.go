@@ -0,0 +1,220 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	PermissionMergeMovies = "movies:merge"
+)
+
+var (
+	ErrMergeIntoItself = errors.New("cannot merge a movie into itself")
+)
+
+// DuplicateCandidate is an existing movie which looks like the one being created
+type DuplicateCandidate struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Year     int32  `json:"year"`
+	Director string `json:"director"`
+	// Similarity is the trigram similarity of the normalised titles, between 0 and 1
+	Similarity   float64 `json:"similarity"`
+	SameDirector bool    `json:"same_director"`
+}
+
+// FindDuplicates looks for movies from a year around the movie whose normalised title is at least
+// threshold similar. Movies by another director are not duplicates, a missing director on either
+// side is not held against a candidate. A title which normalises to nothing, like one made of
+// punctuation only, has no duplicates.
+func (m *MovieModel) FindDuplicates(movie *Movie, threshold float64) ([]*DuplicateCandidate, error) {
+	query := `select id, title, year, director, similarity, director <> '' and lower(director) = lower($3)
+			  from (
+				  select id, title, year, director, similarity(normalize_title(title), normalize_title($1)) as similarity
+				  from movies
+				  where year between $2::integer - 1 and $2::integer + 1
+				  and normalize_title(title) <> '' and normalize_title($1) <> ''
+			  ) as candidates
+			  where similarity >= $4 and ($3 = '' or director = '' or lower(director) = lower($3))
+			  order by similarity desc, abs(year - $2::integer), id
+			  limit 5`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movie.Title, movie.Year, movie.Director, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []*DuplicateCandidate{}
+
+	for rows.Next() {
+		var candidate DuplicateCandidate
+
+		err := rows.Scan(
+			&candidate.ID,
+			&candidate.Title,
+			&candidate.Year,
+			&candidate.Director,
+			&candidate.Similarity,
+			&candidate.SameDirector,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, &candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// movieReferences re-point the rows of every table referencing a movie from the duplicate ($1)
+// to the canonical movie ($2). Rows the canonical movie already has an equivalent of are left
+// behind and removed with the duplicate, the canonical movie wins every conflict.
+var movieReferences = []string{
+	`update ratings set movie_id = $2 where movie_id = $1
+	 and user_id not in (select user_id from ratings where movie_id = $2)`,
+	`update reviews set movie_id = $2 where movie_id = $1
+	 and user_id not in (select user_id from reviews where movie_id = $2)`,
+	`update movie_lists set movie_id = $2 where movie_id = $1
+	 and (user_id, list) not in (select user_id, list from movie_lists where movie_id = $2)`,
+	`update credits set movie_id = $2 where movie_id = $1
+	 and (person_id, role) not in (select person_id, role from credits where movie_id = $2)`,
+	`update movie_translations set movie_id = $2 where movie_id = $1
+	 and locale not in (select locale from movie_translations where movie_id = $2)`,
+	`update releases set movie_id = $2 where movie_id = $1
+	 and (country, release_type) not in (select country, release_type from releases where movie_id = $2)`,
+	`update movie_external_ids set movie_id = $2 where movie_id = $1
+	 and source not in (select source from movie_external_ids where movie_id = $2)`,
+	`update collection_movies set movie_id = $2 where movie_id = $1
+	 and collection_id not in (select collection_id from collection_movies where movie_id = $2)`,
+	`update movie_redirects set movie_id = $2 where movie_id = $1`,
+}
+
+// Merge folds a duplicate into the canonical movie. Everything referencing the duplicate is
+// moved over, the duplicate is deleted and its id redirects to the canonical movie from then on.
+func (m *MovieModel) Merge(duplicateID, canonicalID int64) error {
+	if duplicateID == canonicalID {
+		return ErrMergeIntoItself
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked int
+
+	err = tx.QueryRowContext(ctx,
+		`select count(*) from (select id from movies where id = any($1) order by id for update) as locked`,
+		pq.Array([]int64{duplicateID, canonicalID}),
+	).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	if locked != 2 {
+		return ErrNoRecordsFound
+	}
+
+	// Collections holding both movies lose the duplicate and need their positions closed up
+	rows, err := tx.QueryContext(ctx,
+		`select collection_id from collection_movies where movie_id = $1
+		 and collection_id in (select collection_id from collection_movies where movie_id = $2)`,
+		duplicateID, canonicalID,
+	)
+	if err != nil {
+		return err
+	}
+
+	var collections []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		collections = append(collections, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, query := range movieReferences {
+		_, err = tx.ExecContext(ctx, query, duplicateID, canonicalID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into movie_redirects (old_id, movie_id) values ($1, $2)`, duplicateID, canonicalID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from movies where id = $1`, duplicateID)
+	if err != nil {
+		return err
+	}
+
+	err = renumberCollections(ctx, tx, collections)
+	if err != nil {
+		return err
+	}
+
+	// The canonical movie picked up credits, ratings and more, so it counts as changed
+	canonical := &Movie{ID: canonicalID}
+
+	err = tx.QueryRowContext(ctx,
+		`update movies set version = version + 1 where id = $1 returning version, plot`, canonicalID,
+	).Scan(&canonical.Version, &canonical.Plot)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.unindexMovie(duplicateID)
+	m.indexMovie(canonical)
+	return nil
+}
+
+// GetRedirect returns the movie a merged movie id now points to
+func (m *MovieModel) GetRedirect(id int64) (int64, error) {
+	query := `select movie_id from movie_redirects where old_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var movieID int64
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&movieID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecordsFound
+		}
+		return 0, err
+	}
+
+	return movieID, nil
+}
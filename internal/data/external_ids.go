@@ -0,0 +1,194 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"regexp"
+	"time"
+)
+
+const (
+	SourceIMDb     = "imdb"
+	SourceTMDB     = "tmdb"
+	SourceWikidata = "wikidata"
+)
+
+var (
+	ErrDuplicateExternalID = errors.New("duplicate external id")
+)
+
+// ExternalIDFormats holds the format of the ids of every supported source
+var ExternalIDFormats = map[string]*regexp.Regexp{
+	SourceIMDb:     regexp.MustCompile(`^tt[0-9]{7,10}$`),
+	SourceTMDB:     regexp.MustCompile(`^[1-9][0-9]{0,9}$`),
+	SourceWikidata: regexp.MustCompile(`^Q[1-9][0-9]*$`),
+}
+
+// ExternalIDs maps a source to the id of a movie in that source. When saving a movie
+// an empty id removes the mapping of its source.
+type ExternalIDs map[string]string
+
+func (e ExternalIDs) Value() (driver.Value, error) {
+	if e == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(e)
+}
+
+func (e *ExternalIDs) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("external ids must be scanned from jsonb")
+	}
+	return json.Unmarshal(b, e)
+}
+
+// syncExternalIDs makes the external id mapping of a movie match its ExternalIDs
+// and reads back the copy the trigger keeps on the movie
+func syncExternalIDs(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	sources, ids := []string{}, []string{}
+	for source, id := range movie.ExternalIDs {
+		if id != "" {
+			sources = append(sources, source)
+			ids = append(ids, id)
+		}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`delete from movie_external_ids where movie_id = $1 and not (source = any($2))`,
+		movie.ID, pq.Array(sources),
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `insert into movie_external_ids (movie_id, source, external_id)
+			  select $1, source, external_id from unnest($2::text[], $3::text[]) as wanted(source, external_id)
+			  on conflict (movie_id, source) do update set external_id = excluded.external_id
+			  where movie_external_ids.external_id <> excluded.external_id`
+
+	_, err = tx.ExecContext(ctx, query, movie.ID, pq.Array(sources), pq.Array(ids))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateExternalID
+		}
+		return err
+	}
+
+	return tx.QueryRowContext(ctx, `select external_ids from movies where id = $1`, movie.ID).Scan(&movie.ExternalIDs)
+}
+
+// GetByExternalID finds the movie mapped to the id of an external source
+func (m *MovieModel) GetByExternalID(source, externalID string) (*Movie, error) {
+	var movie Movie
+	columns, dest := movie.movieColumns(nil)
+
+	query := fmt.Sprintf(`select %s from movies
+						  where id = (select movie_id from movie_external_ids where source = $1 and external_id = $2)`, columns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, source, externalID).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+// UpsertByExternalID saves a movie by the id of an external source instead of our id, for
+// imports. The mapped movie is replaced whatever its version, otherwise the movie is created
+// and mapped. The first result tells whether the movie was created.
+func (m *MovieModel) UpsertByExternalID(source, externalID string, movie *Movie) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if movie.ExternalIDs == nil {
+		movie.ExternalIDs = ExternalIDs{}
+	}
+	movie.ExternalIDs[source] = externalID
+
+	query := `select movies.id, movies.version, movies.external_ids
+			  from movie_external_ids inner join movies on movies.id = movie_external_ids.movie_id
+			  where movie_external_ids.source = $1 and movie_external_ids.external_id = $2
+			  for update of movies`
+
+	var existing ExternalIDs
+
+	err = tx.QueryRowContext(ctx, query, source, externalID).Scan(&movie.ID, &movie.Version, &existing)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = insertMovie(ctx, tx, movie)
+		if err != nil {
+			return false, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return false, err
+		}
+		m.indexMovie(movie)
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+
+	// Sources which were not sent keep their mapping
+	for existingSource, id := range existing {
+		if _, ok := movie.ExternalIDs[existingSource]; !ok {
+			movie.ExternalIDs[existingSource] = id
+		}
+	}
+
+	err = updateMovie(ctx, tx, movie)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	m.indexMovie(movie)
+	return false, nil
+}
+
+// ValidateExternalIDs checks the source and format of every external id,
+// empty ids are allowed since they remove a mapping
+func ValidateExternalIDs(v *validator.Validator, ids ExternalIDs) {
+	for source, id := range ids {
+		format, ok := ExternalIDFormats[source]
+		if !ok {
+			v.AddError("external_ids", fmt.Sprintf("%q is not a supported source, use imdb, tmdb or wikidata", source))
+			continue
+		}
+		v.Check(id == "" || format.MatchString(id), "external_ids."+source, fmt.Sprintf("%q is not a valid %s id", id, source))
+	}
+}
+
+// ValidateExternalID checks a single source and id pair, as used by the lookup
+func ValidateExternalID(v *validator.Validator, source, id string) {
+	format, ok := ExternalIDFormats[source]
+	if !ok {
+		v.AddError("source", "source must be imdb, tmdb or wikidata")
+		return
+	}
+	v.Check(format.MatchString(id), "id", fmt.Sprintf("id is not a valid %s id", source))
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MovieFacetSafeList holds the facets which can be requested with ?facets=
+var MovieFacetSafeList = []string{"genres", "decade", "runtime_bucket"}
+
+// movieFacetQueries maps every facet to the value it groups by and the order of
+// its buckets. The genres facet unnests the array so each genre is its own bucket.
+var movieFacetQueries = map[string]struct {
+	from    string
+	value   string
+	orderBy string
+}{
+	"genres": {
+		from:    "movies, unnest(genres) as facet",
+		value:   "facet",
+		orderBy: "count(*) desc, 1 asc",
+	},
+	"decade": {
+		from:    "movies",
+		value:   "(year / 10 * 10)::text || 's'",
+		orderBy: "min(year) asc",
+	},
+	"runtime_bucket": {
+		from: "movies",
+		value: `case when runtime < 90 then 'under 90'
+					  when runtime < 120 then '90-119'
+					  when runtime < 150 then '120-149'
+					  else '150 and over' end`,
+		orderBy: "min(runtime) asc",
+	},
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the movies matching movieQuery for every requested facet. The count
+// is taken over the whole filtered set, pagination doesn't apply, and every facet
+// runs as its own query in parallel under the given context. When GetAll fell back to
+// fuzzy matching, fuzzy counts the titles with the same similarity as the listing.
+func (m *MovieModel) Facets(ctx context.Context, movieQuery MovieQuery, fuzzy bool, facets []string) (map[string][]*FacetCount, error) {
+	movieQuery.fuzzy = fuzzy
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		result   = make(map[string][]*FacetCount, len(facets))
+	)
+
+	for _, facet := range facets {
+		facetQuery, ok := movieFacetQueries[facet]
+		if !ok {
+			panic("unsafe facet parameter: " + facet)
+		}
+
+		wg.Add(1)
+		go func(facet, value, from, orderBy string) {
+			defer wg.Done()
+
+			q := &queryBuilder{}
+			movieQuery.apply(q)
+
+			query := fmt.Sprintf(`select %s, count(*) from %s %s group by 1 order by %s`,
+				value, from, q.whereClause(), orderBy)
+
+			var counts []*FacetCount
+			var err error
+			if movieQuery.fuzzy {
+				// Every facet needs its own transaction, a transaction can't run
+				// queries in parallel
+				err = withSimilarityThreshold(ctx, m.DB, movieQuery.SimilarityThreshold, func(tx *sql.Tx) error {
+					var err error
+					counts, err = m.facetCounts(ctx, tx, query, q.args)
+					return err
+				})
+			} else {
+				counts, err = m.facetCounts(ctx, m.DB, query, q.args)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				// Cancel the other facets, their result is useless without this one
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			result[facet] = counts
+		}(facet, facetQuery.value, facetQuery.from, facetQuery.orderBy)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return result, nil
+}
+
+func (m *MovieModel) facetCounts(ctx context.Context, db querier, query string, args []interface{}) ([]*FacetCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*FacetCount{}
+	for rows.Next() {
+		var count FacetCount
+		err := rows.Scan(&count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, &count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func ValidateFacets(validate *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		if !validator.In(facet, MovieFacetSafeList...) {
+			validate.AddError("facets", fmt.Sprintf("Invalid Facet Value %q", facet))
+			return
+		}
+	}
+	validate.Check(validator.Unique(facets), "facets", "Facets Must Not Contain Duplicate Values")
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"encoding/json"
+	"fmt"
+)
+
+// ValidateFields check that every requested field in a sparse fieldset
+// is one of the entries in the safe list
+func ValidateFields(validate *validator.Validator, fields []string, safeList []string) {
+	for _, field := range fields {
+		if !validator.In(field, safeList...) {
+			validate.AddError("fields", fmt.Sprintf("Invalid Field Value %q", field))
+			return
+		}
+	}
+}
+
+// SelectFields reduces the JSON representation of value to the requested fields.
+// The value is marshalled once and only the raw messages of the wanted keys are kept,
+// so nested values and numbers are passed through untouched.
+// When no fields are requested the value is returned as it is.
+func SelectFields(value interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return value, nil
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	err = json.Unmarshal(js, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if raw, ok := all[field]; ok {
+			selected[field] = raw
+		}
+	}
+
+	return selected, nil
+}
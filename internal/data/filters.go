@@ -11,14 +11,27 @@ type Filters struct {
 	PageSize     int    `json:"pageSize"`
 	Sort         string `json:"sort"`
 	SortSafeList []string
+	// Cursor is the opaque keyset cursor, when it is provided Page is ignored
+	Cursor string `json:"cursor"`
+	// IncludeTotal controls whether the total number of records is counted
+	IncludeTotal bool `json:"includeTotal"`
+	// Fields is the sparse fieldset, an empty list means every field
+	Fields        []string `json:"fields"`
+	FieldSafeList []string
+	cursor        *cursor
+	// fuzzy is set when the page was matched by the fuzzy search fallback, it is
+	// stored in the cursors of the page
+	fuzzy bool
 }
 
 type PaginationMetadata struct {
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	CurrentPage  int `json:"current_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 func calculatePaginationMetadata(totalRecords, page, pageSize int) PaginationMetadata {
@@ -62,12 +75,140 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+// keyset builds the predicate which continues after (or before, when paging
+// backward) the row stored in the cursor. The id is always the tiebreaker and
+// is sorted ascending, so it has to be compared separately from the sort key.
+func (f Filters) keyset(q *queryBuilder, column string) {
+	if f.cursor == nil {
+		return
+	}
+
+	keyOp, idOp := ">", ">"
+	if f.sortDirection() == "DESC" {
+		keyOp = "<"
+	}
+
+	if f.cursor.Backward {
+		keyOp, idOp = flipOperator(keyOp), flipOperator(idOp)
+	}
+
+	value := q.arg(f.cursor.Value)
+	id := q.arg(f.cursor.ID)
+	q.where("(" + column + " " + keyOp + " " + value + " or (" + column + " = " + value + " and id " + idOp + " " + id + "))")
+}
+
+// orderBy returns the order by clause, reversed when the cursor walks backward
+func (f Filters) orderBy(column string) string {
+	direction, idDirection := f.sortDirection(), "ASC"
+
+	if f.cursor != nil && f.cursor.Backward {
+		direction, idDirection = flipDirection(direction), flipDirection(idDirection)
+	}
+
+	return "order by " + column + " " + direction + ", id " + idDirection
+}
+
+// paginationMetadata fills the metadata of a result page. first and last are the
+// boundary rows of the page in display order (nil when the page is empty) and
+// hasMore reports whether the query found a row past the end of the page.
+func (f Filters) paginationMetadata(totalRecords int, hasMore bool, first, last *cursor) PaginationMetadata {
+	metadata := PaginationMetadata{PageSize: f.PageSize}
+
+	if f.cursor == nil {
+		metadata.CurrentPage = f.Page
+		if f.IncludeTotal {
+			metadata = calculatePaginationMetadata(totalRecords, f.Page, f.PageSize)
+		}
+	} else if f.IncludeTotal {
+		metadata.TotalRecords = totalRecords
+	}
+
+	if first == nil || last == nil {
+		return metadata
+	}
+
+	backward := f.cursor != nil && f.cursor.Backward
+
+	// Walking backward we always came from a later page, walking forward we
+	// always came from an earlier one unless this is the very first page
+	first.Fuzzy, last.Fuzzy = f.fuzzy, f.fuzzy
+
+	if hasMore || backward {
+		last.Sort = f.Sort
+		metadata.NextCursor = encodeCursor(*last)
+	}
+
+	if (backward && hasMore) || (!backward && (f.cursor != nil || f.offset() > 0)) {
+		first.Sort, first.Backward = f.Sort, true
+		metadata.PrevCursor = encodeCursor(*first)
+	}
+
+	return metadata
+}
+
+// trimPage drops the extra row which was fetched to find out whether another page
+// exists, and flips a backward page (fetched in reverse order) back for display
+func trimPage[T any](filter Filters, rows []T) ([]T, bool) {
+	hasMore := len(rows) > filter.limit()
+	if hasMore {
+		rows = rows[:filter.limit()]
+	}
+
+	if filter.cursor != nil && filter.cursor.Backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	return rows, hasMore
+}
+
+// pageBoundaries returns the cursors of the first and last row of a page,
+// or nil for both when the page is empty
+func pageBoundaries[T any](rows []T, key func(T) cursor) (*cursor, *cursor) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	first, last := key(rows[0]), key(rows[len(rows)-1])
+	return &first, &last
+}
+
+func flipOperator(op string) string {
+	if op == ">" {
+		return "<"
+	}
+	return ">"
+}
+
+func flipDirection(direction string) string {
+	if direction == "ASC" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
 func ValidateFilters(validate *validator.Validator, filter *Filters) {
 	validate.Check(filter.Page > 0, "page", "CurrentPage is Invalid")
-	validate.Check(filter.Page <= 10_000, "page", "CurrentPage Exceed Maximum")
+	// The page cap only protects the OFFSET scan, cursors may go as deep as needed
+	if filter.Cursor == "" {
+		validate.Check(filter.Page <= 10_000, "page", "CurrentPage Exceed Maximum")
+	}
 
 	validate.Check(filter.PageSize <= 50, "page_size", "CurrentPage Size Exceed Maximum")
 	validate.Check(filter.PageSize > 0, "page_size", "CurrentPage Size is Invalid")
 
 	validate.Check(validator.In(filter.Sort, filter.SortSafeList...), "sort", "Invalid Sort Value")
+
+	ValidateFields(validate, filter.Fields, filter.FieldSafeList)
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			validate.AddError("cursor", "Cursor is Invalid")
+			return
+		}
+		validate.Check(c.Sort == filter.Sort, "cursor", "Cursor Does Not Match Sort Value")
+		filter.cursor = c
+	}
 }
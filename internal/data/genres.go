@@ -0,0 +1,399 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	PermissionManageGenres = "genres:manage"
+)
+
+var (
+	ErrDuplicateGenre = errors.New("duplicate genre")
+	ErrGenreInUse     = errors.New("genre in use")
+)
+
+// Genre is an entry of the managed genre taxonomy. Movies store the slug,
+// the aliases resolve other spellings of the genre to it.
+type Genre struct {
+	ID         int64     `json:"id"`
+	Slug       string    `json:"slug"`
+	Name       string    `json:"name"`
+	Aliases    []string  `json:"aliases"`
+	MovieCount int       `json:"movie_count"`
+	CreatedAt  time.Time `json:"-"`
+	Version    int32     `json:"-"`
+}
+
+// slugLigatures are the letters unaccent spells out instead of dropping an accent
+var slugLigatures = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ł", "l")
+
+// Slugify lowercases a genre, drops the accents of Latin letters and collapses
+// punctuation and spaces into single dashes, letters and marks of any script are kept.
+// It must stay in line with genre_slug() in the database.
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	latin := false
+
+	for _, r := range norm.NFD.String(slugLigatures.Replace(strings.ToLower(s))) {
+		switch {
+		case unicode.Is(unicode.Mn, r) && latin:
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+		latin = unicode.Is(unicode.Latin, r)
+	}
+
+	return norm.NFC.String(b.String())
+}
+
+// GenreTaxonomy maps the slug and every alias of a genre to the slug
+type GenreTaxonomy map[string]string
+
+// Resolve returns the slug of a genre given any of its spellings
+func (t GenreTaxonomy) Resolve(genre string) (string, bool) {
+	slug, ok := t[Slugify(genre)]
+	return slug, ok
+}
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+func (m *GenreModel) Insert(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkGenreSpellings(ctx, tx, genre)
+	if err != nil {
+		return err
+	}
+
+	query := `insert into genres (slug, name, aliases) values ($1, $2, $3) returning id, created_at, version`
+
+	err = tx.QueryRowContext(ctx, query, genre.Slug, genre.Name, pq.Array(genre.Aliases)).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Version,
+	)
+	if err != nil {
+		return genreError(err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrNoRecordsFound
+	}
+
+	query := `select id, slug, name, aliases, created_at, version,
+			  (select count(*) from movies where movies.genres @> array[genres.slug])
+			  from genres where id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var genre Genre
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Aliases),
+		&genre.CreatedAt,
+		&genre.Version,
+		&genre.MovieCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &genre, nil
+}
+
+// GetAll returns the whole taxonomy ordered by name together with the number of movies in each genre
+func (m *GenreModel) GetAll() ([]*Genre, error) {
+	query := `select genres.id, genres.slug, genres.name, genres.aliases, genres.created_at, genres.version,
+			  coalesce(counts.total, 0)
+			  from genres left join (
+				  select unnest(movies.genres) as slug, count(*) as total from movies group by 1
+			  ) as counts on counts.slug = genres.slug
+			  order by genres.name, genres.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(
+			&genre.ID,
+			&genre.Slug,
+			&genre.Name,
+			pq.Array(&genre.Aliases),
+			&genre.CreatedAt,
+			&genre.Version,
+			&genre.MovieCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+// Taxonomy loads the lookup used by ValidateMovie to resolve the genres of a movie
+func (m *GenreModel) Taxonomy() (GenreTaxonomy, error) {
+	query := `select slug, aliases from genres`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	taxonomy := GenreTaxonomy{}
+
+	for rows.Next() {
+		var slug string
+		var aliases []string
+
+		err := rows.Scan(&slug, pq.Array(&aliases))
+		if err != nil {
+			return nil, err
+		}
+
+		taxonomy[slug] = slug
+		for _, alias := range aliases {
+			taxonomy[alias] = slug
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return taxonomy, nil
+}
+
+// Update saves the genre. Changing the slug renames the genre on every movie
+// and keeps the old slug as an alias so existing filters keep working.
+func (m *GenreModel) Update(genre *Genre) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+
+	err = tx.QueryRowContext(ctx, `select slug from genres where id = $1 and version = $2 for update`, genre.ID, genre.Version).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	if previous != genre.Slug && !validator.In(previous, genre.Aliases...) {
+		genre.Aliases = append(genre.Aliases, previous)
+	}
+
+	err = checkGenreSpellings(ctx, tx, genre)
+	if err != nil {
+		return err
+	}
+
+	query := `update genres set slug = $1, name = $2, aliases = $3, version = version + 1
+			  where id = $4
+			  returning version`
+
+	err = tx.QueryRowContext(ctx, query, genre.Slug, genre.Name, pq.Array(genre.Aliases), genre.ID).Scan(&genre.Version)
+	if err != nil {
+		return genreError(err)
+	}
+
+	if previous != genre.Slug {
+		err = replaceMovieGenre(ctx, tx, previous, genre.Slug)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a genre. A genre still used by movies can only be deleted by merging it
+// into a replacement genre, which takes over its movies, slug and aliases.
+func (m *GenreModel) Delete(id int64, replacement string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var slug string
+	var aliases []string
+
+	err = tx.QueryRowContext(ctx, `delete from genres where id = $1 returning slug, aliases`, id).Scan(&slug, pq.Array(&aliases))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecordsFound
+		}
+		return err
+	}
+
+	if replacement == "" {
+		var used bool
+
+		err = tx.QueryRowContext(ctx, `select exists (select 1 from movies where genres @> array[$1])`, slug).Scan(&used)
+		if err != nil {
+			return err
+		}
+
+		if used {
+			return ErrGenreInUse
+		}
+
+		return tx.Commit()
+	}
+
+	query := `update genres set aliases = array(select distinct unnest(aliases || $1::text[])), version = version + 1
+			  where slug = $2 and slug <> $3`
+
+	result, err := tx.ExecContext(ctx, query, pq.Array(append(aliases, slug)), replacement, slug)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	err = replaceMovieGenre(ctx, tx, slug, replacement)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkGenreSpellings makes sure neither the slug nor the aliases of the genre
+// already resolve to another genre
+func checkGenreSpellings(ctx context.Context, tx *sql.Tx, genre *Genre) error {
+	spellings := append([]string{genre.Slug}, genre.Aliases...)
+
+	query := `select exists (
+				  select 1 from genres
+				  where id <> $1 and (slug = any($2) or aliases && $2)
+			  )`
+
+	var taken bool
+
+	err := tx.QueryRowContext(ctx, query, genre.ID, pq.Array(spellings)).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrDuplicateGenre
+	}
+
+	return nil
+}
+
+// replaceMovieGenre swaps a genre slug for another on every movie, dropping the duplicate when a
+// movie already has both. The movie version is left alone since the movie itself didn't change.
+func replaceMovieGenre(ctx context.Context, tx *sql.Tx, from, to string) error {
+	query := `update movies set genres = array(
+				  select genre from unnest(array_replace(movies.genres, $1, $2)) with ordinality as g(genre, position)
+				  group by genre order by min(position)
+			  )
+			  where genres @> array[$1]`
+
+	_, err := tx.ExecContext(ctx, query, from, to)
+	return err
+}
+
+func genreError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateGenre
+	}
+	return err
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Slug != "", "slug", "slug must be provided")
+	v.Check(genre.Slug == Slugify(genre.Slug), "slug", "slug must only contain lowercase letters, digits and single dashes")
+	v.Check(len(genre.Slug) <= 50, "slug", "slug max length is 50 characters")
+
+	v.Check(genre.Name != "", "name", "name must be provided")
+	v.Check(len(genre.Name) <= 50, "name", "name max length is 50 characters")
+
+	// Aliases are stored the way they are looked up
+	for i := range genre.Aliases {
+		genre.Aliases[i] = Slugify(genre.Aliases[i])
+	}
+
+	v.Check(len(genre.Aliases) <= 20, "aliases", "aliases must not contain more than 20 values")
+	v.Check(!validator.In("", genre.Aliases...), "aliases", "aliases must contain a letter or digit")
+	v.Check(validator.Unique(genre.Aliases), "aliases", "aliases must not contain duplicate values")
+	v.Check(!validator.In(genre.Slug, genre.Aliases...), "aliases", "aliases must not contain the slug")
+}
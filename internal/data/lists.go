@@ -0,0 +1,242 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	ListWatchlist  = "watchlist"
+	ListFavourites = "favourites"
+)
+
+// ListEntry is a movie on the watchlist or favourites of a user
+type ListEntry struct {
+	Movie     *Movie     `json:"movie"`
+	Watched   bool       `json:"watched"`
+	WatchedAt *time.Time `json:"watched_at,omitempty"`
+	AddedAt   time.Time  `json:"added_at"`
+}
+
+// MovieListStatus tells whether a movie is on the lists of the calling user
+type MovieListStatus struct {
+	Watchlist bool `json:"watchlist"`
+	Favourite bool `json:"favourite"`
+	Watched   bool `json:"watched"`
+}
+
+// MovieListModel wraps the movie_lists table. The table has no id column of its
+// own, so the id tiebreaker used by the Filters keyset resolves to movies.id.
+type MovieListModel struct {
+	DB *sql.DB
+}
+
+// Add puts a movie on a list, adding a movie which is already there only updates the watched flag
+func (m *MovieListModel) Add(userID int64, list string, movieID int64, watched bool) (*ListEntry, error) {
+	query := `insert into movie_lists (user_id, list, movie_id, watched, watched_at)
+			  values ($1, $2, $3, $4, case when $4 then now() end)
+			  on conflict (user_id, list, movie_id) do update
+			  set watched = excluded.watched,
+			      watched_at = case when excluded.watched then coalesce(movie_lists.watched_at, now()) end
+			  returning watched, watched_at, added_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := &ListEntry{Movie: &Movie{ID: movieID}}
+
+	err := m.DB.QueryRowContext(ctx, query, userID, list, movieID, watched).Scan(
+		&entry.Watched,
+		&entry.WatchedAt,
+		&entry.AddedAt,
+	)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// SetWatched marks a movie already on the list as watched or not watched
+func (m *MovieListModel) SetWatched(userID int64, list string, movieID int64, watched bool) (*ListEntry, error) {
+	query := `update movie_lists
+			  set watched = $4, watched_at = case when $4 then coalesce(watched_at, now()) end
+			  where user_id = $1 and list = $2 and movie_id = $3
+			  returning watched, watched_at, added_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := &ListEntry{Movie: &Movie{ID: movieID}}
+
+	err := m.DB.QueryRowContext(ctx, query, userID, list, movieID, watched).Scan(
+		&entry.Watched,
+		&entry.WatchedAt,
+		&entry.AddedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (m *MovieListModel) Remove(userID int64, list string, movieID int64) error {
+	query := `delete from movie_lists where user_id = $1 and list = $2 and movie_id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, list, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+// GetAll lists the movies on a list of a user, sortable by the date added or by movie columns
+func (m *MovieListModel) GetAll(userID int64, list string, filter Filters) ([]*ListEntry, PaginationMetadata, error) {
+	q := &queryBuilder{}
+
+	q.where(fmt.Sprintf("movie_lists.user_id = %s", q.arg(userID)))
+	q.where(fmt.Sprintf("movie_lists.list = %s", q.arg(list)))
+
+	countQuery := q.clone()
+
+	sortColumn := filter.sortColumn()
+	sortExpression := sortColumn
+	if column, ok := movieSortColumns[sortColumn]; ok {
+		sortExpression = column
+	}
+
+	filter.keyset(q, sortExpression)
+
+	totalColumn := "0"
+	if filter.IncludeTotal && filter.cursor == nil {
+		totalColumn = "count(*) over()"
+	}
+
+	pagination := fmt.Sprintf("limit %s", q.arg(filter.limit()+1))
+	if filter.cursor == nil {
+		pagination += fmt.Sprintf(" offset %s", q.arg(filter.offset()))
+	}
+
+	columns, _ := new(Movie).movieColumns(nil)
+
+	query := fmt.Sprintf(`select %s, movie_lists.watched, movie_lists.watched_at, movie_lists.added_at, %s
+						  from movie_lists inner join movies on movies.id = movie_lists.movie_id
+						  %s %s %s`,
+		totalColumn, columns, q.whereClause(), filter.orderBy(sortExpression), pagination)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	entries := []*ListEntry{}
+
+	for rows.Next() {
+		entry := ListEntry{Movie: &Movie{}}
+
+		_, dest := entry.Movie.movieColumns(nil)
+		dest = append([]interface{}{&totalRecords, &entry.Watched, &entry.WatchedAt, &entry.AddedAt}, dest...)
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+
+	entries, hasMore := trimPage(filter, entries)
+
+	if filter.IncludeTotal && filter.cursor != nil {
+		query := fmt.Sprintf(`select count(*) from movie_lists %s`, countQuery.whereClause())
+		err = m.DB.QueryRowContext(ctx, query, countQuery.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+	}
+
+	first, last := pageBoundaries(entries, func(entry *ListEntry) cursor {
+		value := entry.AddedAt.Format(time.RFC3339)
+		if sortColumn != "added_at" {
+			value = entry.Movie.sortValue(sortColumn)
+		}
+		return cursor{Value: value, ID: entry.Movie.ID}
+	})
+
+	return entries, filter.paginationMetadata(totalRecords, hasMore, first, last), nil
+}
+
+// StatusForMovies looks up the list status of many movies for a user in a single query,
+// so a listing page doesn't need a lookup per movie. Movies on no list are left out.
+func (m *MovieListModel) StatusForMovies(userID int64, movieIDs []int64) (map[int64]*MovieListStatus, error) {
+	query := `select movie_id,
+			  bool_or(list = 'watchlist'), bool_or(list = 'favourites'), bool_or(list = 'watchlist' and watched)
+			  from movie_lists
+			  where user_id = $1 and movie_id = any($2)
+			  group by movie_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[int64]*MovieListStatus, len(movieIDs))
+
+	for rows.Next() {
+		var movieID int64
+		var status MovieListStatus
+
+		err := rows.Scan(&movieID, &status.Watchlist, &status.Favourite, &status.Watched)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses[movieID] = &status
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
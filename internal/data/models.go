@@ -13,17 +13,37 @@ var (
 // Create a Models struct which wraps the MovieModel. I'll add other models to this,
 // like a UserModel and PermissionModel, as the build progresses
 type Models struct {
-	Movie  *MovieModel
-	User   *UserModel
-	Tokens *TokenModel
+	Movie        *MovieModel
+	User         *UserModel
+	Tokens       *TokenModel
+	Rating       *RatingModel
+	Permissions  *PermissionModel
+	Reviews      *ReviewModel
+	Lists        *MovieListModel
+	Collections  *CollectionModel
+	People       *PersonModel
+	Credits      *CreditModel
+	Genres       *GenreModel
+	Translations *TranslationModel
+	Releases     *ReleaseModel
 }
 
 // For ease of use, I also add a New() method which returns a Models struct containing
 // the initialized MovieModel.
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movie:  &MovieModel{DB: db},
-		User:   &UserModel{DB: db},
-		Tokens: &TokenModel{DB: db},
+		Movie:        &MovieModel{DB: db},
+		User:         &UserModel{DB: db},
+		Tokens:       &TokenModel{DB: db},
+		Rating:       &RatingModel{DB: db},
+		Permissions:  &PermissionModel{DB: db},
+		Reviews:      &ReviewModel{DB: db},
+		Lists:        &MovieListModel{DB: db},
+		Collections:  &CollectionModel{DB: db},
+		People:       &PersonModel{DB: db},
+		Credits:      &CreditModel{DB: db},
+		Genres:       &GenreModel{DB: db},
+		Translations: &TranslationModel{DB: db},
+		Releases:     &ReleaseModel{DB: db},
 	}
 }
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// MovieQuery holds the optional filters of a movie listing.
+// Every zero value means the filter is not applied.
+type MovieQuery struct {
+	Title string `json:"title"`
+	// Search matches title, director, actors and plot through the weighted search_vector
+	Search        string    `json:"q"`
+	GenresAll     []string  `json:"genres_all"`
+	GenresAny     []string  `json:"genres_any"`
+	YearMin       int       `json:"year_min"`
+	YearMax       int       `json:"year_max"`
+	RuntimeMin    int       `json:"runtime_min"`
+	RuntimeMax    int       `json:"runtime_max"`
+	Director      string    `json:"director"`
+	Actor         string    `json:"actor"`
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+	// Region and Certification match movies with a release in the country and with the
+	// certification, both on the same release when both are given
+	Region        string `json:"region"`
+	Certification string `json:"certification"`
+	// MinVotes drops movies with fewer ratings, so a single 10 doesn't top sort=-rating
+	MinVotes int `json:"min_votes"`
+	// SimilarityThreshold is the pg_trgm word similarity a title needs to match
+	// when the search falls back to fuzzy matching, zero disables the fallback
+	SimilarityThreshold float64 `json:"-"`
+	// Locales are the negotiated locales of the caller, a title search also matches
+	// their translated titles. Without locales any translation matches.
+	Locales []string `json:"-"`
+	fuzzy   bool
+}
+
+// apply adds a where condition for every filter that has been set. Values are
+// always passed as arguments, only the fixed SQL fragments are formatted in.
+func (mq MovieQuery) apply(q *queryBuilder) {
+	// implement full text search on title...
+	// to_tsvector() takes a string and split to lexemes (one word or several word)
+	// specifying 'simple' means transpose the title to lowercase
+	// plainto_tsquery() function takes a search value and turns into formatted query term PostgreSQL
+	// the @@ operator is the matches operator. In our statement we are using it to check whether
+	// the generated query term matches the lexemes.
+	// The fuzzy variant matches titles by trigram word similarity instead, the
+	// <% operator uses the trigram index with the threshold of the transaction
+	if mq.Title != "" {
+		if mq.fuzzy {
+			q.where(fmt.Sprintf("%s <%% title", q.arg(mq.Title)))
+		} else {
+			// Translated titles are matched with the text search configuration of their language
+			title := q.arg(mq.Title)
+			translated := "true"
+			if len(mq.Locales) > 0 {
+				translated = fmt.Sprintf("split_part(t.locale, '-', 1) = any(%s)", q.arg(pq.Array(localeLanguages(mq.Locales))))
+			}
+			q.where(fmt.Sprintf(`(to_tsvector('simple', title) @@ plainto_tsquery('simple', %[1]s) or exists (
+				select 1 from movie_translations t where t.movie_id = movies.id and %[2]s
+				and t.search_vector @@ plainto_tsquery(t.search_config, %[1]s)))`, title, translated))
+		}
+	}
+
+	if mq.Search != "" {
+		if mq.fuzzy {
+			q.where(fmt.Sprintf("%s <%% title", q.arg(mq.Search)))
+		} else {
+			q.where(fmt.Sprintf("search_vector @@ websearch_to_tsquery('simple', %s)", q.arg(mq.Search)))
+		}
+	}
+
+	// @> matches movies having all the genres, && matches any of them.
+	// resolve_genres() turns aliases and other spellings into the stored slugs.
+	if len(mq.GenresAll) > 0 {
+		q.where(fmt.Sprintf("genres @> resolve_genres(%s)", q.arg(pq.Array(mq.GenresAll))))
+	}
+
+	if len(mq.GenresAny) > 0 {
+		q.where(fmt.Sprintf("genres && resolve_genres(%s)", q.arg(pq.Array(mq.GenresAny))))
+	}
+
+	if mq.YearMin != 0 {
+		q.where(fmt.Sprintf("year >= %s", q.arg(mq.YearMin)))
+	}
+
+	if mq.YearMax != 0 {
+		q.where(fmt.Sprintf("year <= %s", q.arg(mq.YearMax)))
+	}
+
+	if mq.RuntimeMin != 0 {
+		q.where(fmt.Sprintf("runtime >= %s", q.arg(mq.RuntimeMin)))
+	}
+
+	if mq.RuntimeMax != 0 {
+		q.where(fmt.Sprintf("runtime <= %s", q.arg(mq.RuntimeMax)))
+	}
+
+	// Both use the expression indexes from the filter indexes migration
+	if mq.Director != "" {
+		q.where(fmt.Sprintf("lower(director) = lower(%s)", q.arg(mq.Director)))
+	}
+
+	if mq.Actor != "" {
+		q.where(fmt.Sprintf("lower_text_array(actors) @> array[lower(%s)]", q.arg(mq.Actor)))
+	}
+
+	if !mq.CreatedAfter.IsZero() {
+		q.where(fmt.Sprintf("created_at > %s", q.arg(mq.CreatedAfter)))
+	}
+
+	if !mq.CreatedBefore.IsZero() {
+		q.where(fmt.Sprintf("created_at < %s", q.arg(mq.CreatedBefore)))
+	}
+
+	if mq.Region != "" || mq.Certification != "" {
+		release := "true"
+		if mq.Region != "" {
+			release = fmt.Sprintf("r.country = %s", q.arg(mq.Region))
+		}
+		if mq.Certification != "" {
+			release += fmt.Sprintf(" and lower(r.certification) = lower(%s)", q.arg(mq.Certification))
+		}
+		q.where(fmt.Sprintf("exists (select 1 from releases r where r.movie_id = movies.id and %s)", release))
+	}
+
+	if mq.MinVotes != 0 {
+		q.where(fmt.Sprintf("rating_count >= %s", q.arg(mq.MinVotes)))
+	}
+}
+
+func ValidateMovieQuery(v *validator.Validator, mq *MovieQuery) {
+	v.Check(len(mq.Title) <= 100, "title", "title max length is 100 characters")
+	v.Check(len(mq.Search) <= 200, "q", "q max length is 200 characters")
+
+	v.Check(validator.Unique(mq.GenresAll), "genres_all", "genres must not contain duplicate values")
+	v.Check(validator.Unique(mq.GenresAny), "genres_any", "genres must not contain duplicate values")
+
+	v.Check(mq.Region == "" || CountryRX.MatchString(mq.Region), "region", "region must be an ISO 3166-1 alpha-2 code like US")
+	v.Check(len(mq.Certification) <= 20, "certification", "certification max length is 20 characters")
+
+	v.Check(mq.YearMin >= 0, "year_min", "year_min is invalid")
+	v.Check(mq.YearMax >= 0, "year_max", "year_max is invalid")
+	if mq.YearMin != 0 && mq.YearMax != 0 {
+		v.Check(mq.YearMin <= mq.YearMax, "year_min", "year_min must not be greater than year_max")
+	}
+
+	v.Check(mq.RuntimeMin >= 0, "runtime_min", "runtime_min is invalid")
+	v.Check(mq.RuntimeMax >= 0, "runtime_max", "runtime_max is invalid")
+	if mq.RuntimeMin != 0 && mq.RuntimeMax != 0 {
+		v.Check(mq.RuntimeMin <= mq.RuntimeMax, "runtime_min", "runtime_min must not be greater than runtime_max")
+	}
+
+	v.Check(len(mq.Director) <= 255, "director", "director max length is 255 characters")
+	v.Check(len(mq.Actor) <= 255, "actor", "actor max length is 255 characters")
+
+	v.Check(mq.MinVotes >= 0, "min_votes", "min_votes is invalid")
+
+	if !mq.CreatedAfter.IsZero() && !mq.CreatedBefore.IsZero() {
+		v.Check(mq.CreatedAfter.Before(mq.CreatedBefore), "created_after", "created_after must be before created_before")
+	}
+}
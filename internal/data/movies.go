@@ -7,21 +7,52 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Movie struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year"`
-	Runtime   int32     `json:"runtime"`
-	Genres    []string  `json:"genres"`
-	Director  string    `json:"director"`
-	Actors    []string  `json:"actors"`
-	Plot      string    `json:"plot"`
-	PosterURL string    `json:"poster_url"`
-	CreatedAt time.Time `json:"-"`
-	Version   int32     `json:"-"`
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Year      int32    `json:"year"`
+	Runtime   int32    `json:"runtime"`
+	Genres    []string `json:"genres"`
+	Director  string   `json:"director"`
+	Actors    []string `json:"actors"`
+	Plot      string   `json:"plot"`
+	PosterURL string   `json:"poster_url"`
+	// PosterKey is the storage key of an uploaded poster, PosterURL then points at our storage
+	PosterKey string `json:"-"`
+	// PosterSrcset holds the scaled down sizes of an uploaded poster once they are generated
+	PosterSrcset PosterSrcset `json:"poster_srcset"`
+	// FirstReleaseDate is the earliest release anywhere, maintained from the releases
+	FirstReleaseDate *Date `json:"first_release_date"`
+	// ReleaseYears are the years of the releases, a movie may only have a future year it is released in
+	ReleaseYears []int32 `json:"-"`
+	// RatingAverage and RatingCount are maintained from the ratings table, zero votes averages to 0
+	RatingAverage float64   `json:"rating_average"`
+	RatingCount   int32     `json:"rating_count"`
+	CreatedAt     time.Time `json:"-"`
+	Version       int32     `json:"-"`
+	// Lists is only filled for an authenticated caller
+	Lists *MovieListStatus `json:"lists,omitempty"`
+	// Locale is the translation the title and plot were swapped for, OriginalTitle then holds the untranslated title
+	Locale        string `json:"locale,omitempty"`
+	OriginalTitle string `json:"original_title,omitempty"`
+	// ExternalIDs maps a source like imdb to the id of the movie there
+	ExternalIDs ExternalIDs `json:"external_ids"`
+	// Highlights is only filled when the listing is searched with q
+	Highlights *MovieHighlights `json:"highlights,omitempty"`
+	// rank is the negated search relevance, used as the sort key of sort=relevance
+	rank float32
+}
+
+// MovieHighlights holds ts_headline() snippets with the search matches wrapped in <mark>
+type MovieHighlights struct {
+	Title string `json:"title"`
+	Plot  string `json:"plot"`
 }
 
 /*
@@ -34,17 +65,60 @@ type Movie struct {
 // MovieModel Define a MovieModel struct type which wraps a sql.DB connection pool.
 type MovieModel struct {
 	DB *sql.DB
+	// Index is told about every movie written or deleted once committed, when set
+	Index MovieIndex
+}
+
+// MovieIndex is an in-memory index over movies which has to follow every change
+type MovieIndex interface {
+	Put(id int64, version int32, plot string)
+	Remove(id int64)
+}
+
+func (m *MovieModel) indexMovie(movie *Movie) {
+	if m.Index != nil {
+		m.Index.Put(movie.ID, movie.Version, movie.Plot)
+	}
+}
+
+func (m *MovieModel) unindexMovie(id int64) {
+	if m.Index != nil {
+		m.Index.Remove(id)
+	}
 }
 
 // Insert If the receiver is a struct or array, any of whose elements is a pointer to something that may be mutated,
 // prefer a pointer receiver to make the intention of mutability clear to the reader.
 func (m *MovieModel) Insert(movie *Movie) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// The movie, its director and actor credits and its external ids are written together
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertMovie(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.indexMovie(movie)
+	return nil
+}
+
+func insertMovie(ctx context.Context, tx *sql.Tx, movie *Movie) error {
 	query := `insert into movies (title, year, runtime, genres, director, actors, plot, poster_url)
 			  values($1, $2, $3, $4, $5, $6, $7, $8)
-			  returning id, created_at, version`
+			  returning id, created_at, version, poster_srcset`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
 	// Use pq.Array to type cast []string to type array in postgres before executing
 	args := []interface{}{
 		movie.Title,
@@ -63,41 +137,89 @@ func (m *MovieModel) Insert(movie *Movie) error {
 	// Use the QueryRow() method to execute the SQL query on the connection pool,
 	// passing in the args slice as a variadic parameter and scanning the system
 	// generated id, created_at and version values into the movie struct.
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &movie.PosterSrcset)
+	if err != nil {
+		return err
+	}
+
+	err = syncMovieCredits(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	return syncExternalIDs(ctx, tx, movie)
+}
+
+// MovieFieldSafeList holds the fields of a movie which can be requested with ?fields=
+var MovieFieldSafeList = []string{"id", "title", "year", "runtime", "genres", "director", "actors", "plot", "poster_url", "poster_srcset", "first_release_date", "external_ids", "rating_average", "rating_count", "lists", "highlights"}
+
+// movieColumns returns the select list for the requested fields together with
+// the destinations to scan them into. No fields means every column.
+// The id is always selected since it identifies the row and ends up in the cursor.
+func (movie *Movie) movieColumns(fields []string) (string, []interface{}) {
+	all := []struct {
+		column string
+		dest   interface{}
+	}{
+		{"id", &movie.ID},
+		{"title", &movie.Title},
+		{"year", &movie.Year},
+		{"runtime", &movie.Runtime},
+		{"genres", pq.Array(&movie.Genres)},
+		{"director", &movie.Director},
+		{"actors", pq.Array(&movie.Actors)},
+		{"plot", &movie.Plot},
+		{"poster_url", &movie.PosterURL},
+		{"poster_key", &movie.PosterKey},
+		{"poster_srcset", &movie.PosterSrcset},
+		{"first_release_date", &movie.FirstReleaseDate},
+		{"release_years", pq.Array(&movie.ReleaseYears)},
+		{"external_ids", &movie.ExternalIDs},
+		{"rating_average", &movie.RatingAverage},
+		{"rating_count", &movie.RatingCount},
+		{"created_at", &movie.CreatedAt},
+		{"version", &movie.Version},
+	}
+
+	var columns []string
+	var dest []interface{}
+
+	for _, c := range all {
+		if len(fields) == 0 || c.column == "id" || validator.In(c.column, fields...) {
+			columns = append(columns, c.column)
+			dest = append(dest, c.dest)
+		}
+	}
+
+	return strings.Join(columns, ", "), dest
 }
 
 func (m *MovieModel) Get(id int64) (*Movie, error) {
+	return m.GetFields(id, nil)
+}
+
+// GetFields fetch a single movie but only select the columns of the requested fields
+func (m *MovieModel) GetFields(id int64, fields []string) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrNoRecordsFound
 	}
 
-	query := `select * from movies where id = $1`
-
 	// Declare a Movie struct to hold the data returned by the query.
 	var movie Movie
 
+	columns, dest := movie.movieColumns(fields)
+	query := fmt.Sprintf(`select %s from movies where id = $1`, columns)
+
 	// Set timeout query for 3 seconds
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	// The defer means context will always be released before the Get() method returns,
 	// thereby preventing a memory leak
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Director,
-		pq.Array(&movie.Actors),
-		&movie.Plot,
-		&movie.PosterURL,
-		&movie.CreatedAt,
-		&movie.Version,
-	)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(dest...)
 
 	if err != nil {
-		if errors.Is(err, ErrNoRecordsFound) {
+		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecordsFound
 		}
 		return nil, err
@@ -106,29 +228,186 @@ func (m *MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m *MovieModel) GetAll(title string, genres []string, filter Filters) ([]*Movie, PaginationMetadata, error) {
-	// @> means array operator for Postgres
-	// implement full text search on title...
-	// to_tsvector() takes a string and split to lexemes (one word or several word)
-	// specifying 'simple' means transpose the title to lowercase
-	// plainto_tsquery() function takes a search value and turns into formatted query term PostgreSQL
-	// the @@ operator is the matches operator. In our statement we are using it to check whether
-	// the generated query term matches the lexemes.
+// GetMany fetches the movies with the given ids in the order of the ids, ids
+// of movies which don't exist are skipped
+func (m *MovieModel) GetMany(ids []int64) ([]*Movie, error) {
+	movies := []*Movie{}
+	if len(ids) == 0 {
+		return movies, nil
+	}
 
-	// Also this workaround using fmt.Sprintf() since order by has no placeholder for arguments
-	query := fmt.Sprintf(
-		`select count(*) over(), * from movies 
-         		where (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) or $1 = '')
-			  	and (genres @> $2 or $2 = '{}')
-			  	order by %s %s, id asc 
-			  	limit $3 offset $4`, filter.sortColumn(), filter.sortDirection(),
-	)
+	columns, _ := (&Movie{}).movieColumns(nil)
+	query := fmt.Sprintf(`select %s from movies where id = any($1) order by array_position($1, id)`, columns)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filter.limit(), filter.offset()}
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+
+		_, dest := movie.movieColumns(nil)
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// GetAll lists the movies matching movieQuery and reports whether the search fell back
+// to fuzzy matching, Facets needs to know to count the same movies
+func (m *MovieModel) GetAll(movieQuery MovieQuery, filter Filters) ([]*Movie, PaginationMetadata, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// A misspelled search term finds nothing with full-text search, so the search is
+	// retried matching the title by trigram similarity instead. The cursors of a fuzzy
+	// page remember it, so the following pages match the same way.
+	searching := movieQuery.Title != "" || movieQuery.Search != ""
+	fallback := searching && movieQuery.SimilarityThreshold > 0
+
+	if fallback && filter.cursor != nil && filter.cursor.Fuzzy {
+		return m.getAllFuzzy(ctx, movieQuery, filter)
+	}
+
+	movies, paginationMetadata, err := m.getAll(ctx, m.DB, movieQuery, filter)
+	if err != nil {
+		return nil, PaginationMetadata{}, false, err
+	}
+
+	if len(movies) > 0 || !fallback || filter.cursor != nil {
+		return movies, paginationMetadata, false, nil
+	}
+
+	// An empty page past the first only falls back when nothing matches at all,
+	// otherwise it is just past the end of the full-text results
+	if filter.Page != 1 {
+		matched, err := m.anyMatch(ctx, movieQuery)
+		if err != nil {
+			return nil, PaginationMetadata{}, false, err
+		}
+		if matched {
+			return movies, paginationMetadata, false, nil
+		}
+	}
+
+	return m.getAllFuzzy(ctx, movieQuery, filter)
+}
+
+// getAllFuzzy runs the listing matching titles by trigram similarity
+func (m *MovieModel) getAllFuzzy(ctx context.Context, movieQuery MovieQuery, filter Filters) ([]*Movie, PaginationMetadata, bool, error) {
+	movieQuery.fuzzy = true
+	filter.fuzzy = true
+
+	var movies []*Movie
+	var paginationMetadata PaginationMetadata
+
+	err := withSimilarityThreshold(ctx, m.DB, movieQuery.SimilarityThreshold, func(tx *sql.Tx) error {
+		var err error
+		movies, paginationMetadata, err = m.getAll(ctx, tx, movieQuery, filter)
+		return err
+	})
+	if err != nil {
+		return nil, PaginationMetadata{}, false, err
+	}
+
+	return movies, paginationMetadata, true, nil
+}
+
+// anyMatch reports whether any movie matches the query
+func (m *MovieModel) anyMatch(ctx context.Context, movieQuery MovieQuery) (bool, error) {
+	q := &queryBuilder{}
+	movieQuery.apply(q)
+
+	var matched bool
+	err := m.DB.QueryRowContext(ctx, fmt.Sprintf(`select exists (select 1 from movies %s)`, q.whereClause()), q.args...).Scan(&matched)
+	return matched, err
+}
+
+func (m *MovieModel) getAll(ctx context.Context, db querier, movieQuery MovieQuery, filter Filters) ([]*Movie, PaginationMetadata, error) {
+	q := &queryBuilder{}
+	movieQuery.apply(q)
+
+	// Keep the filter only conditions around for the count query, the keyset
+	// predicate must not reduce the total
+	countQuery := q.clone()
+
+	sortColumn := filter.sortColumn()
+	sortExpression := sortColumn
+	if column, ok := movieSortColumns[sortColumn]; ok {
+		sortExpression = column
+	}
+
+	// Extra expressions selected after the movie columns, only used when searching with q
+	var searchColumns string
+	if movieQuery.Search != "" {
+		term := q.arg(movieQuery.Search)
+		search := fmt.Sprintf("websearch_to_tsquery('simple', %s)", term)
+
+		// The rank is negated so relevance can be sorted ascending like every
+		// other column, which keeps the keyset predicate and cursor the same.
+		// A fuzzy match has no full-text rank, it is ranked by title similarity.
+		rank := fmt.Sprintf("(-ts_rank(search_vector, %s))", search)
+		if movieQuery.fuzzy {
+			rank = fmt.Sprintf("(-word_similarity(%s, title))", term)
+		}
+
+		if sortColumn == "relevance" {
+			sortExpression = rank
+		}
+
+		// ts_headline() is expensive, Postgres postpones it until after the
+		// order by and limit so it only runs for the rows of this page
+		options := q.arg("StartSel=<mark>, StopSel=</mark>, MaxFragments=2")
+		searchColumns = fmt.Sprintf(
+			", %[1]s, ts_headline('simple', title, %[2]s, %[3]s), ts_headline('simple', plot, %[2]s, %[3]s)",
+			rank, search, options,
+		)
+	}
+
+	filter.keyset(q, sortExpression)
+
+	// A cursor page is counted with a separate query, an offset page keeps using
+	// the window function so it stays a single round trip
+	totalColumn := "0"
+	if filter.IncludeTotal && filter.cursor == nil {
+		totalColumn = "count(*) over()"
+	}
+
+	// The sort column is needed for the cursor even when it was not requested
+	fields := filter.Fields
+	if len(fields) > 0 {
+		fields = append([]string{sortExpression}, fields...)
+	}
+	columns, _ := new(Movie).movieColumns(fields)
+
+	pagination := fmt.Sprintf("limit %s", q.arg(filter.limit()+1))
+	if filter.cursor == nil {
+		pagination += fmt.Sprintf(" offset %s", q.arg(filter.offset()))
+	}
+
+	// Also this workaround using fmt.Sprintf() since order by has no placeholder for arguments
+	query := fmt.Sprintf(
+		`select %s, %s%s from movies 
+         		%s
+			  	%s 
+			  	%s`, totalColumn, columns, searchColumns, q.whereClause(), filter.orderBy(sortExpression), pagination,
+	)
+
+	rows, err := db.QueryContext(ctx, query, q.args...)
 
 	if err != nil {
 		return nil, PaginationMetadata{}, err
@@ -142,20 +421,15 @@ func (m *MovieModel) GetAll(title string, genres []string, filter Filters) ([]*M
 	for rows.Next() {
 		var movie Movie
 
-		err := rows.Scan(
-			&totalRecords,
-			&movie.ID,
-			&movie.Title,
-			&movie.Year,
-			&movie.Runtime,
-			pq.Array(&movie.Genres),
-			&movie.Director,
-			pq.Array(&movie.Actors),
-			&movie.Plot,
-			&movie.PosterURL,
-			&movie.CreatedAt,
-			&movie.Version,
-		)
+		_, dest := movie.movieColumns(fields)
+		dest = append([]interface{}{&totalRecords}, dest...)
+
+		if movieQuery.Search != "" {
+			movie.Highlights = &MovieHighlights{}
+			dest = append(dest, &movie.rank, &movie.Highlights.Title, &movie.Highlights.Plot)
+		}
+
+		err := rows.Scan(dest...)
 
 		if err != nil {
 			return nil, PaginationMetadata{}, err
@@ -164,14 +438,103 @@ func (m *MovieModel) GetAll(title string, genres []string, filter Filters) ([]*M
 		movies = append(movies, &movie)
 	}
 
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
 		return nil, PaginationMetadata{}, err
 	}
 
-	paginationMetadata := calculatePaginationMetadata(totalRecords, filter.Page, filter.PageSize)
+	movies, hasMore := trimPage(filter, movies)
+
+	if filter.IncludeTotal && filter.cursor != nil {
+		query := fmt.Sprintf(`select count(*) from movies %s`, countQuery.whereClause())
+		err = db.QueryRowContext(ctx, query, countQuery.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+	}
+
+	first, last := pageBoundaries(movies, func(movie *Movie) cursor {
+		return cursor{Value: movie.sortValue(sortColumn), ID: movie.ID}
+	})
+
+	paginationMetadata := filter.paginationMetadata(totalRecords, hasMore, first, last)
 	return movies, paginationMetadata, nil
 }
 
+// movieSortColumns maps the sort values which are not named after their column
+var movieSortColumns = map[string]string{
+	"rating": "rating_average",
+}
+
+// sortValue returns the value of a sortable column as text, it is stored in the
+// pagination cursor and compared against the column again by Postgres
+func (movie *Movie) sortValue(column string) string {
+	switch column {
+	case "title":
+		return movie.Title
+	case "year":
+		return strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		return strconv.FormatInt(int64(movie.Runtime), 10)
+	case "rating":
+		return strconv.FormatFloat(movie.RatingAverage, 'g', -1, 64)
+	case "relevance":
+		// Format with 32 bit precision so the value parses back to the exact same real
+		return strconv.FormatFloat(float64(movie.rank), 'g', -1, 32)
+	default:
+		return strconv.FormatInt(movie.ID, 10)
+	}
+}
+
+// MovieSuggestion is a single autocomplete entry
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
+
+// Autocomplete returns up to limit titles starting with prefix, topped up with titles
+// similar to it. The prefix match uses the lower(title) pattern index and the
+// similarity match the trigram index, Postgres combines both with a bitmap or.
+func (m *MovieModel) Autocomplete(prefix string, limit int, threshold float64) ([]*MovieSuggestion, error) {
+	query := `select id, title, year from movies
+			  where lower(title) like $1 or $2 <% title
+			  order by lower(title) like $1 desc, word_similarity($2, title) desc, title asc, id asc
+			  limit $3`
+
+	// Suggestions are requested on every keystroke, a slow one is worthless
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	pattern := likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+
+	var suggestions []*MovieSuggestion
+
+	err := withSimilarityThreshold(ctx, m.DB, threshold, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, pattern, prefix, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var suggestion MovieSuggestion
+			err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+			if err != nil {
+				return err
+			}
+			suggestions = append(suggestions, &suggestion)
+		}
+
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
 func (m *MovieModel) Count() (int, error) {
 	query := `select count(*) from movies`
 
@@ -183,20 +546,44 @@ func (m *MovieModel) Count() (int, error) {
 	err := m.DB.QueryRowContext(ctx, query).Scan(&count)
 
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
 	return count, nil
 }
 func (m *MovieModel) Update(movie *Movie) error {
-	query := `update movies set title = $1, year = $2, runtime = $3, genres = $4, 
-              director = $5, actors = $6, plot = $7, poster_url = $8, version = version + 1 
-              where id = $9 and version = $10 
-              returning version`
-
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateMovie(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.indexMovie(movie)
+	return nil
+}
+
+func updateMovie(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	// A poster_url pointing elsewhere than the uploaded poster detaches the upload
+	query := `update movies set title = $1, year = $2, runtime = $3, genres = $4, 
+              director = $5, actors = $6, plot = $7, poster_url = $8, version = version + 1,
+              poster_key = case when poster_url = $8 then poster_key else '' end,
+              poster_srcset = case when poster_url = $8 then poster_srcset else '{}' end
+              where id = $9 and version = $10 
+              returning version, poster_key, poster_srcset`
+
 	args := []interface{}{
 		&movie.Title,
 		&movie.Year,
@@ -210,7 +597,7 @@ func (m *MovieModel) Update(movie *Movie) error {
 		&movie.Version,
 	}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version, &movie.PosterKey, &movie.PosterSrcset)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrEditConflict
@@ -218,16 +605,80 @@ func (m *MovieModel) Update(movie *Movie) error {
 		return err
 	}
 
+	err = syncMovieCredits(ctx, tx, movie)
+	if err != nil {
+		return err
+	}
+
+	return syncExternalIDs(ctx, tx, movie)
+}
+
+// SetPoster points the movie at an uploaded poster
+func (m *MovieModel) SetPoster(movie *Movie, key, url string) error {
+	query := `update movies set poster_key = $1, poster_url = $2, poster_srcset = '{}', version = version + 1
+			  where id = $3 and version = $4
+			  returning version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, key, url, movie.ID, movie.Version).Scan(&movie.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	movie.PosterKey = key
+	movie.PosterURL = url
+	movie.PosterSrcset = PosterSrcset{}
+
 	return nil
 }
 
 func (m *MovieModel) Delete(id int64) error {
-	query := `delete from movies where id = $1`
-
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Deleting the movie takes it out of its collections, which are locked in id order
+	// like any other change to their positions and renumbered afterwards
+	rows, err := tx.QueryContext(ctx,
+		`update collections set updated_at = now()
+		 where id in (
+			 select id from collections
+			 where id in (select collection_id from collection_movies where movie_id = $1)
+			 order by id for update
+		 )
+		 returning id`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	var collections []int64
+	for rows.Next() {
+		var collectionID int64
+		if err := rows.Scan(&collectionID); err != nil {
+			rows.Close()
+			return err
+		}
+		collections = append(collections, collectionID)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `delete from movies where id = $1`, id)
 	if err != nil {
 		return err
 	}
@@ -241,14 +692,88 @@ func (m *MovieModel) Delete(id int64) error {
 		return ErrNoRecordsFound
 	}
 
+	err = renumberCollections(ctx, tx, collections)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.unindexMovie(id)
 	return nil
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// GetPlotVersions returns the version of every movie with a plot, so an index over
+// the plots can tell which movies changed since it was snapshotted
+func (m *MovieModel) GetPlotVersions() (map[int64]int32, error) {
+	query := `select id, version from movies where plot <> ''`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]int32)
+
+	for rows.Next() {
+		var id int64
+		var version int32
+
+		err := rows.Scan(&id, &version)
+		if err != nil {
+			return nil, err
+		}
+
+		versions[id] = version
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// ValidateMovie also resolves the genres of the movie against the taxonomy,
+// replacing aliases and other spellings with the genre slug
+func ValidateMovie(v *validator.Validator, movie *Movie, taxonomy GenreTaxonomy) {
 	v.Check(movie.Title != "", "title", "title must be provided")
 	v.Check(len(movie.Title) <= 100, "title", "title max length is 100 characters")
 
 	v.Check(movie.Year != 0, "year", "year must be provided")
 	v.Check(movie.Year >= 0, "year", "year is invalid")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "year cannot be in the future")
+
+	// Unreleased movies may be catalogued a few years ahead. Once a movie has releases a
+	// future year needs one dated in that year, and once it has been released its year
+	// cannot be later than that release.
+	currentYear := int32(time.Now().Year())
+	if len(movie.ReleaseYears) == 0 {
+		v.Check(movie.Year <= currentYear+MaxYearsAhead, "year", fmt.Sprintf("year cannot be more than %d years in the future", MaxYearsAhead))
+	} else {
+		v.Check(movie.Year <= currentYear || slices.Contains(movie.ReleaseYears, movie.Year), "year", "year can only be in the future when the movie has a release dated in that year")
+	}
+	if movie.FirstReleaseDate != nil && !movie.FirstReleaseDate.After(time.Now()) {
+		v.Check(movie.Year <= int32(movie.FirstReleaseDate.Year()), "year", "year cannot be later than the first release of the movie")
+	}
+
+	v.Check(len(movie.Genres) >= 1, "genres", "genres must contain at least 1 genre")
+	v.Check(len(movie.Genres) <= 5, "genres", "genres must not contain more than 5 genres")
+
+	for i, genre := range movie.Genres {
+		slug, ok := taxonomy.Resolve(genre)
+		if !ok {
+			v.AddError("genres", fmt.Sprintf("%q is not a known genre", genre))
+			continue
+		}
+		movie.Genres[i] = slug
+	}
+
+	v.Check(validator.Unique(movie.Genres), "genres", "genres must not contain duplicate values")
 }
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+// testDB connects to the migrated database in TEST_POSTGRES_URL, the tests which need
+// one are skipped without it
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// A misspelled search falls back to fuzzy matching on the first page, the pages after
+// it have to keep matching fuzzily whether they are reached by cursor or by page number
+func TestGetAllPagesThroughFuzzySearch(t *testing.T) {
+	m := &MovieModel{DB: testDB(t)}
+
+	titles := []string{"Quixotrombulator Returns", "Quixotrombulator Rises", "Quixotrombulator Forever"}
+	for _, title := range titles {
+		movie := &Movie{Title: title, Year: 2001, Runtime: 100, Genres: []string{"drama"}}
+		if err := m.Insert(movie); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { m.Delete(movie.ID) })
+	}
+
+	// Every call gets a fresh query, nothing may carry over from an earlier page
+	query := func(title string) MovieQuery {
+		return MovieQuery{Title: title, SimilarityThreshold: 0.3}
+	}
+
+	filters := func(page int, cursor string) Filters {
+		f := Filters{Page: page, PageSize: 1, Cursor: cursor, Sort: "id", SortSafeList: []string{"id"}, IncludeTotal: true}
+		if cursor != "" {
+			c, err := decodeCursor(cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.cursor = c
+		}
+		return f
+	}
+
+	seen := make(map[int64]bool)
+	cursor := ""
+
+	for page := 1; page <= len(titles); page++ {
+		movies, metadata, fuzzy, err := m.GetAll(query("Quixotrombulatr"), filters(1, cursor))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fuzzy {
+			t.Errorf("page %d by cursor: not matched fuzzily", page)
+		}
+		if len(movies) != 1 {
+			t.Fatalf("page %d by cursor: got %d movies, want 1", page, len(movies))
+		}
+		if metadata.TotalRecords != len(titles) {
+			t.Errorf("page %d by cursor: got %d total records, want %d", page, metadata.TotalRecords, len(titles))
+		}
+		if seen[movies[0].ID] {
+			t.Errorf("page %d by cursor: movie %d was already on an earlier page", page, movies[0].ID)
+		}
+		seen[movies[0].ID] = true
+
+		if page < len(titles) && metadata.NextCursor == "" {
+			t.Fatalf("page %d by cursor: no next cursor", page)
+		}
+		cursor = metadata.NextCursor
+	}
+
+	// The pages past the first find nothing by full-text search, anyMatch has to tell
+	// them apart from pages past the end of the full-text results
+	for page := 1; page <= len(titles); page++ {
+		movies, metadata, fuzzy, err := m.GetAll(query("Quixotrombulatr"), filters(page, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fuzzy {
+			t.Errorf("page %d: not matched fuzzily", page)
+		}
+		if len(movies) != 1 {
+			t.Fatalf("page %d: got %d movies, want 1", page, len(movies))
+		}
+		if metadata.LastPage != len(titles) {
+			t.Errorf("page %d: got last page %d, want %d", page, metadata.LastPage, len(titles))
+		}
+	}
+
+	movies, _, _, err := m.GetAll(query("Quixotrombulatr"), filters(len(titles)+1, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 0 {
+		t.Errorf("misspelled page past the end: got %d movies, want 0", len(movies))
+	}
+
+	// Spelled right the page past the end stays empty without falling back
+	movies, _, fuzzy, err := m.GetAll(query("Quixotrombulator"), filters(len(titles)+1, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 0 || fuzzy {
+		t.Errorf("page past the end: got %d movies and fuzzy %v, want 0 and false", len(movies), fuzzy)
+	}
+}
@@ -0,0 +1,179 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type Person struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"-"`
+	Version   int32     `json:"-"`
+}
+
+// PersonModel wraps the people table. Renaming a person rebuilds the director
+// and actors columns of their movies through a trigger.
+type PersonModel struct {
+	DB *sql.DB
+}
+
+func (m *PersonModel) Insert(person *Person) error {
+	query := `insert into people (name) values ($1) returning id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, person.Name).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m *PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrNoRecordsFound
+	}
+
+	query := `select id, name, created_at, version from people where id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var person Person
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&person.ID, &person.Name, &person.CreatedAt, &person.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &person, nil
+}
+
+// GetAll searches people by a case-insensitive part of their name, backed by the trigram index
+func (m *PersonModel) GetAll(name string, filter Filters) ([]*Person, PaginationMetadata, error) {
+	q := &queryBuilder{}
+
+	if name != "" {
+		pattern := "%" + likeEscaper.Replace(name) + "%"
+		q.where(fmt.Sprintf("lower(name) like lower(%s)", q.arg(pattern)))
+	}
+
+	countQuery := q.clone()
+
+	sortColumn := filter.sortColumn()
+	filter.keyset(q, sortColumn)
+
+	totalColumn := "0"
+	if filter.IncludeTotal && filter.cursor == nil {
+		totalColumn = "count(*) over()"
+	}
+
+	pagination := fmt.Sprintf("limit %s", q.arg(filter.limit()+1))
+	if filter.cursor == nil {
+		pagination += fmt.Sprintf(" offset %s", q.arg(filter.offset()))
+	}
+
+	query := fmt.Sprintf(`select %s, id, name, created_at, version from people %s %s %s`,
+		totalColumn, q.whereClause(), filter.orderBy(sortColumn), pagination)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	people := []*Person{}
+
+	for rows.Next() {
+		var person Person
+
+		err := rows.Scan(&totalRecords, &person.ID, &person.Name, &person.CreatedAt, &person.Version)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+
+	people, hasMore := trimPage(filter, people)
+
+	if filter.IncludeTotal && filter.cursor != nil {
+		query := fmt.Sprintf(`select count(*) from people %s`, countQuery.whereClause())
+		err = m.DB.QueryRowContext(ctx, query, countQuery.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+	}
+
+	first, last := pageBoundaries(people, func(person *Person) cursor {
+		value := strconv.FormatInt(person.ID, 10)
+		if sortColumn == "name" {
+			value = person.Name
+		}
+		return cursor{Value: value, ID: person.ID}
+	})
+
+	return people, filter.paginationMetadata(totalRecords, hasMore, first, last), nil
+}
+
+func (m *PersonModel) Update(person *Person) error {
+	query := `update people set name = $1, version = version + 1
+			  where id = $2 and version = $3
+			  returning version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, person.Name, person.ID, person.Version).Scan(&person.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a person together with their credits
+func (m *PersonModel) Delete(id int64) error {
+	query := `delete from people where id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "name must be provided")
+	v.Check(len(person.Name) <= 255, "name", "name max length is 255 characters")
+}
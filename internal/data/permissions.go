@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	PermissionModerateReviews = "reviews:moderate"
+	// PermissionWriteMovies guards the edits of what hangs off a movie, like its credits and releases
+	PermissionWriteMovies = "movies:write"
+)
+
+// PermissionCodes lists every permission code the migrations create
+var PermissionCodes = []string{PermissionModerateReviews, PermissionWriteMovies, PermissionMergeMovies, PermissionManageGenres}
+
+// Permissions holds the permission codes of a single user
+type Permissions []string
+
+// Include check whether the Permissions slice contains a specific permission code
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser returns every permission code granted to a user
+func (m *PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	query := `select permissions.code
+			  from permissions
+			  inner join users_permissions on users_permissions.permission_id = permissions.id
+			  where users_permissions.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+
+	for rows.Next() {
+		var permission string
+
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// AddForUser grants the given permission codes to a user
+func (m *PermissionModel) AddForUser(userID int64, codes ...string) error {
+	query := `insert into users_permissions
+			  select $1, permissions.id from permissions where permissions.code = any($2)
+			  on conflict do nothing`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// PosterSrcset maps a poster size like "w185" to the URL of the poster scaled to that width
+type PosterSrcset map[string]string
+
+func (s PosterSrcset) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *PosterSrcset) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("poster srcset must be scanned from jsonb")
+	}
+	return json.Unmarshal(b, s)
+}
+
+// PosterRef identifies the uploaded poster of a movie
+type PosterRef struct {
+	MovieID int64
+	Key     string
+}
+
+// SetPosterSrcset stores the derived poster sizes. They are only saved when the movie still
+// has the poster they were derived from, a newer upload brings its own derivatives.
+func (m *MovieModel) SetPosterSrcset(poster PosterRef, srcset PosterSrcset) error {
+	query := `update movies set poster_srcset = $1 where id = $2 and poster_key = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, srcset, poster.MovieID, poster.Key)
+	return err
+}
+
+// GetAllPosters returns the uploaded poster of every movie which has one
+func (m *MovieModel) GetAllPosters() ([]PosterRef, error) {
+	query := `select id, poster_key from movies where poster_key <> '' order by id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posters []PosterRef
+
+	for rows.Next() {
+		var poster PosterRef
+
+		err := rows.Scan(&poster.MovieID, &poster.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		posters = append(posters, poster)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posters, nil
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so a query can run
+// on the pool or inside a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// likeEscaper escapes the LIKE wildcards of user input used as a pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// queryBuilder collects where conditions together with their positional
+// arguments, so optional filters can be composed without hand-numbering
+// the $n placeholders.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg appends a value to the argument list and returns its placeholder
+func (q *queryBuilder) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where adds a condition which will be joined with the others using AND
+func (q *queryBuilder) where(condition string) {
+	q.conditions = append(q.conditions, condition)
+}
+
+// whereClause renders every collected condition, or an empty string when
+// there is nothing to filter on
+func (q *queryBuilder) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return "where " + strings.Join(q.conditions, " and ")
+}
+
+// clone copies the builder so that extra conditions can be added to one query
+// (e.g. a keyset predicate) without leaking into another (e.g. the count query)
+func (q *queryBuilder) clone() *queryBuilder {
+	return &queryBuilder{
+		conditions: append([]string{}, q.conditions...),
+		args:       append([]interface{}{}, q.args...),
+	}
+}
+
+// withSimilarityThreshold runs fn inside a read only transaction with the pg_trgm
+// word similarity threshold set locally. The <% operator compares against this
+// setting and, unlike word_similarity(), is able to use the trigram index.
+func withSimilarityThreshold(ctx context.Context, db *sql.DB, threshold float64, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `select set_config('pg_trgm.word_similarity_threshold', $1, true)`,
+		strconv.FormatFloat(threshold, 'f', -1, 64))
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,138 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+type Rating struct {
+	UserID    int64     `json:"user_id"`
+	MovieID   int64     `json:"movie_id"`
+	Score     int32     `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RatingModel wraps the ratings table. The rating_count and rating_total columns
+// of movies are maintained by a trigger on this table, so they never need
+// to be recomputed when a movie is read.
+type RatingModel struct {
+	DB *sql.DB
+}
+
+// Upsert stores the rating of a user, replacing the score when the user already rated the movie
+func (m *RatingModel) Upsert(rating *Rating) error {
+	query := `insert into ratings (user_id, movie_id, score)
+			  values ($1, $2, $3)
+			  on conflict (user_id, movie_id) do update set score = excluded.score, updated_at = now()
+			  returning created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{rating.UserID, rating.MovieID, rating.Score}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&rating.CreatedAt, &rating.UpdatedAt)
+	if err != nil {
+		// A foreign key violation means the movie doesn't exist
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNoRecordsFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *RatingModel) Delete(userID, movieID int64) error {
+	query := `delete from ratings where user_id = $1 and movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+// GetAll returns every rating, it is meant for training the recommendations in the background
+func (m *RatingModel) GetAll() ([]*Rating, error) {
+	query := `select user_id, movie_id, score, created_at, updated_at from ratings`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return m.scanRatings(ctx, query)
+}
+
+// GetForUser returns the ratings of one user, the most recent first
+func (m *RatingModel) GetForUser(userID int64) ([]*Rating, error) {
+	query := `select user_id, movie_id, score, created_at, updated_at from ratings
+			  where user_id = $1 order by updated_at desc, movie_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.scanRatings(ctx, query, userID)
+}
+
+// GetForUserMovies returns the ratings a user gave to any of the movies, movies
+// the user didn't rate are left out
+func (m *RatingModel) GetForUserMovies(userID int64, movieIDs []int64) ([]*Rating, error) {
+	query := `select user_id, movie_id, score, created_at, updated_at from ratings
+			  where user_id = $1 and movie_id = any($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.scanRatings(ctx, query, userID, pq.Array(movieIDs))
+}
+
+func (m *RatingModel) scanRatings(ctx context.Context, query string, args ...interface{}) ([]*Rating, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []*Rating{}
+
+	for rows.Next() {
+		var rating Rating
+
+		err := rows.Scan(&rating.UserID, &rating.MovieID, &rating.Score, &rating.CreatedAt, &rating.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		ratings = append(ratings, &rating)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ratings, nil
+}
+
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.Check(rating.Score >= 1, "score", "score must be at least 1")
+	v.Check(rating.Score <= 10, "score", "score must not be more than 10")
+}
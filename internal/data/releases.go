@@ -0,0 +1,344 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"regexp"
+	"time"
+)
+
+const (
+	ReleaseTheatrical = "theatrical"
+	ReleaseDigital    = "digital"
+	ReleaseFestival   = "festival"
+)
+
+// MaxYearsAhead is how far ahead a movie without releases may be catalogued, it must
+// stay in line with movies_year_check
+const MaxYearsAhead = 5
+
+var (
+	ErrDuplicateRelease = errors.New("duplicate release")
+	// ErrReleaseYear is returned when the releases of a movie with a future year would no
+	// longer have one dated in that year
+	ErrReleaseYear = errors.New("the year of the movie needs this release")
+)
+
+// CountryRX matches an ISO 3166-1 alpha-2 country code
+var CountryRX = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Date is a calendar day without a time, it is written to JSON as 2006-01-02
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.Format(time.DateOnly) + `"`), nil
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	t, err := time.Parse(`"`+time.DateOnly+`"`, string(b))
+	if err != nil {
+		return errors.New("date must be formatted as YYYY-MM-DD")
+	}
+	d.Time = t
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.Format(time.DateOnly), nil
+}
+
+func (d *Date) Scan(src interface{}) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into a date", src)
+	}
+	d.Time = t
+	return nil
+}
+
+type Release struct {
+	ID            int64  `json:"id"`
+	MovieID       int64  `json:"movie_id"`
+	Country       string `json:"country"`
+	ReleaseDate   Date   `json:"release_date"`
+	ReleaseType   string `json:"release_type"`
+	Certification string `json:"certification"`
+	// Movie is only filled in the upcoming releases listing
+	Movie   *Movie `json:"movie,omitempty"`
+	Version int32  `json:"-"`
+}
+
+// ReleaseModel wraps the releases table. The first_release_date column of movies
+// is maintained from it by a trigger.
+type ReleaseModel struct {
+	DB *sql.DB
+}
+
+func (m *ReleaseModel) Insert(release *Release) error {
+	query := `insert into releases (movie_id, country, release_date, release_type, certification)
+			  values ($1, $2, $3, $4, $5)
+			  returning id, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{release.MovieID, release.Country, release.ReleaseDate, release.ReleaseType, release.Certification}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&release.ID, &release.Version)
+	if err != nil {
+		return releaseError(err)
+	}
+
+	return nil
+}
+
+func (m *ReleaseModel) Get(movieID, releaseID int64) (*Release, error) {
+	query := `select id, movie_id, country, release_date, release_type, certification, version
+			  from releases where id = $1 and movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var release Release
+
+	err := m.DB.QueryRowContext(ctx, query, releaseID, movieID).Scan(
+		&release.ID,
+		&release.MovieID,
+		&release.Country,
+		&release.ReleaseDate,
+		&release.ReleaseType,
+		&release.Certification,
+		&release.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &release, nil
+}
+
+// GetForMovie returns the releases of a movie by date
+func (m *ReleaseModel) GetForMovie(movieID int64) ([]*Release, error) {
+	query := `select id, movie_id, country, release_date, release_type, certification, version
+			  from releases where movie_id = $1
+			  order by release_date, country, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []*Release{}
+
+	for rows.Next() {
+		var release Release
+
+		err := rows.Scan(
+			&release.ID,
+			&release.MovieID,
+			&release.Country,
+			&release.ReleaseDate,
+			&release.ReleaseType,
+			&release.Certification,
+			&release.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		releases = append(releases, &release)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// Upcoming lists the releases from a day on by date, optionally for one country and release type
+func (m *ReleaseModel) Upcoming(from time.Time, country, releaseType string, filter Filters) ([]*Release, PaginationMetadata, error) {
+	q := &queryBuilder{}
+
+	q.where(fmt.Sprintf("release_date >= %s", q.arg(from.Format(time.DateOnly))))
+
+	if country != "" {
+		q.where(fmt.Sprintf("country = %s", q.arg(country)))
+	}
+
+	if releaseType != "" {
+		q.where(fmt.Sprintf("release_type = %s", q.arg(releaseType)))
+	}
+
+	countQuery := q.clone()
+
+	sortColumn := filter.sortColumn()
+	filter.keyset(q, sortColumn)
+
+	totalColumn := "0"
+	if filter.IncludeTotal && filter.cursor == nil {
+		totalColumn = "count(*) over()"
+	}
+
+	pagination := fmt.Sprintf("limit %s", q.arg(filter.limit()+1))
+	if filter.cursor == nil {
+		pagination += fmt.Sprintf(" offset %s", q.arg(filter.offset()))
+	}
+
+	// The join is wrapped so the keyset condition can refer to the release id as id
+	upcoming := `(select releases.id, releases.movie_id, releases.country, releases.release_date, releases.release_type,
+				 releases.certification, releases.version, movies.title, movies.year, movies.poster_url
+				 from releases inner join movies on movies.id = releases.movie_id) as upcoming`
+
+	query := fmt.Sprintf(`select %s, id, movie_id, country, release_date, release_type, certification, version,
+						  title, year, poster_url
+						  from %s %s %s %s`,
+		totalColumn, upcoming, q.whereClause(), filter.orderBy(sortColumn), pagination)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	releases := []*Release{}
+
+	for rows.Next() {
+		release := Release{Movie: &Movie{}}
+
+		err := rows.Scan(
+			&totalRecords,
+			&release.ID,
+			&release.MovieID,
+			&release.Country,
+			&release.ReleaseDate,
+			&release.ReleaseType,
+			&release.Certification,
+			&release.Version,
+			&release.Movie.Title,
+			&release.Movie.Year,
+			&release.Movie.PosterURL,
+		)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+
+		release.Movie.ID = release.MovieID
+		releases = append(releases, &release)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+
+	releases, hasMore := trimPage(filter, releases)
+
+	if filter.IncludeTotal && filter.cursor != nil {
+		query := fmt.Sprintf(`select count(*) from releases %s`, countQuery.whereClause())
+		err = m.DB.QueryRowContext(ctx, query, countQuery.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+	}
+
+	first, last := pageBoundaries(releases, func(release *Release) cursor {
+		return cursor{Value: release.ReleaseDate.Format(time.DateOnly), ID: release.ID}
+	})
+
+	return releases, filter.paginationMetadata(totalRecords, hasMore, first, last), nil
+}
+
+func (m *ReleaseModel) Update(release *Release) error {
+	query := `update releases set country = $1, release_date = $2, release_type = $3, certification = $4,
+			  version = version + 1
+			  where id = $5 and version = $6
+			  returning version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{
+		release.Country,
+		release.ReleaseDate,
+		release.ReleaseType,
+		release.Certification,
+		release.ID,
+		release.Version,
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&release.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return releaseError(err)
+	}
+
+	return nil
+}
+
+func (m *ReleaseModel) Delete(movieID, releaseID int64) error {
+	query := `delete from releases where id = $1 and movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, releaseID, movieID)
+	if err != nil {
+		return releaseError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+func releaseError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return ErrDuplicateRelease
+		case "23503":
+			return ErrNoRecordsFound
+		case "23514":
+			if pqErr.Constraint == "movies_year_check" {
+				return ErrReleaseYear
+			}
+		}
+	}
+	return err
+}
+
+func ValidateRelease(v *validator.Validator, release *Release) {
+	v.Check(CountryRX.MatchString(release.Country), "country", "country must be an ISO 3166-1 alpha-2 code like US")
+	v.Check(!release.ReleaseDate.IsZero(), "release_date", "release_date must be provided")
+	v.Check(release.ReleaseDate.Year() >= 1900, "release_date", "release_date is invalid")
+	v.Check(validator.In(release.ReleaseType, ReleaseTheatrical, ReleaseDigital, ReleaseFestival), "release_type", "release_type must be theatrical, digital or festival")
+	v.Check(len(release.Certification) <= 20, "certification", "certification max length is 20 characters")
+}
@@ -1,11 +1,12 @@
 package data
 
 type Response struct {
-	Status     bool                `json:"status"`
-	StatusCode int                 `json:"statusCode"`
-	Result     interface{}         `json:"result"`
-	Message    string              `json:"message"`
-	Pagination *PaginationMetadata `json:"pagination,omitempty"`
+	Status     bool                     `json:"status"`
+	StatusCode int                      `json:"statusCode"`
+	Result     interface{}              `json:"result"`
+	Message    string                   `json:"message"`
+	Pagination *PaginationMetadata      `json:"pagination,omitempty"`
+	Facets     map[string][]*FacetCount `json:"facets,omitempty"`
 }
 
 func NewResponse() Response {
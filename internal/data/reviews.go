@@ -0,0 +1,268 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strconv"
+	"time"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+var (
+	ErrDuplicateReview = errors.New("duplicate review")
+)
+
+type Review struct {
+	ID               int64      `json:"id"`
+	MovieID          int64      `json:"movie_id"`
+	UserID           int64      `json:"user_id"`
+	Title            string     `json:"title"`
+	Body             string     `json:"body"`
+	Status           string     `json:"status"`
+	ModerationReason string     `json:"moderation_reason,omitempty"`
+	ModeratedBy      *int64     `json:"-"`
+	ModeratedAt      *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Version          int32      `json:"-"`
+}
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// reviewColumns is the select list matching reviewDest
+const reviewColumns = `id, movie_id, user_id, title, body, status, moderation_reason,
+					   moderated_by, moderated_at, created_at, updated_at, version`
+
+func (review *Review) reviewDest() []interface{} {
+	return []interface{}{
+		&review.ID,
+		&review.MovieID,
+		&review.UserID,
+		&review.Title,
+		&review.Body,
+		&review.Status,
+		&review.ModerationReason,
+		&review.ModeratedBy,
+		&review.ModeratedAt,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	}
+}
+
+// Insert creates a pending review, a user may only review a movie once
+func (m *ReviewModel) Insert(review *Review) error {
+	query := `insert into reviews (movie_id, user_id, title, body)
+			  values ($1, $2, $3, $4)
+			  returning id, status, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{review.MovieID, review.UserID, review.Title, review.Body}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&review.ID,
+		&review.Status,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Version,
+	)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return ErrDuplicateReview
+			case "23503":
+				return ErrNoRecordsFound
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *ReviewModel) Get(id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrNoRecordsFound
+	}
+
+	query := fmt.Sprintf(`select %s from reviews where id = $1`, reviewColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var review Review
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(review.reviewDest()...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// GetAll lists the reviews in a status, optionally limited to a single movie
+// when movieID is not zero. It paginates the same way as the movie listing.
+func (m *ReviewModel) GetAll(movieID int64, status string, filter Filters) ([]*Review, PaginationMetadata, error) {
+	q := &queryBuilder{}
+
+	q.where(fmt.Sprintf("status = %s", q.arg(status)))
+
+	if movieID != 0 {
+		q.where(fmt.Sprintf("movie_id = %s", q.arg(movieID)))
+	}
+
+	countQuery := q.clone()
+
+	sortColumn := filter.sortColumn()
+	filter.keyset(q, sortColumn)
+
+	totalColumn := "0"
+	if filter.IncludeTotal && filter.cursor == nil {
+		totalColumn = "count(*) over()"
+	}
+
+	pagination := fmt.Sprintf("limit %s", q.arg(filter.limit()+1))
+	if filter.cursor == nil {
+		pagination += fmt.Sprintf(" offset %s", q.arg(filter.offset()))
+	}
+
+	query := fmt.Sprintf(`select %s, %s from reviews %s %s %s`,
+		totalColumn, reviewColumns, q.whereClause(), filter.orderBy(sortColumn), pagination)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, review.reviewDest()...)...)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PaginationMetadata{}, err
+	}
+
+	reviews, hasMore := trimPage(filter, reviews)
+
+	if filter.IncludeTotal && filter.cursor != nil {
+		query := fmt.Sprintf(`select count(*) from reviews %s`, countQuery.whereClause())
+		err = m.DB.QueryRowContext(ctx, query, countQuery.args...).Scan(&totalRecords)
+		if err != nil {
+			return nil, PaginationMetadata{}, err
+		}
+	}
+
+	first, last := pageBoundaries(reviews, func(review *Review) cursor {
+		return cursor{Value: review.sortValue(sortColumn), ID: review.ID}
+	})
+
+	return reviews, filter.paginationMetadata(totalRecords, hasMore, first, last), nil
+}
+
+func (review *Review) sortValue(column string) string {
+	switch column {
+	case "created_at":
+		return review.CreatedAt.Format(time.RFC3339)
+	case "updated_at":
+		return review.UpdatedAt.Format(time.RFC3339)
+	default:
+		return strconv.FormatInt(review.ID, 10)
+	}
+}
+
+// Update saves an edit by the author. The edited text has to be moderated
+// again, so the review goes back to pending and the previous decision is cleared.
+func (m *ReviewModel) Update(review *Review) error {
+	query := `update reviews set title = $1, body = $2, status = 'pending', moderation_reason = '',
+			  moderated_by = null, moderated_at = null, updated_at = now(), version = version + 1
+			  where id = $3 and version = $4
+			  returning status, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{review.Title, review.Body, review.ID, review.Version}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.Status, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	review.ModerationReason, review.ModeratedBy, review.ModeratedAt = "", nil, nil
+	return nil
+}
+
+// Decide records the decision of a moderator on a review
+func (m *ReviewModel) Decide(review *Review, status, reason string, moderatorID int64) error {
+	query := `update reviews set status = $1, moderation_reason = $2, moderated_by = $3,
+			  moderated_at = now(), version = version + 1
+			  where id = $4 and version = $5
+			  returning moderated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{status, reason, moderatorID, review.ID, review.Version}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.ModeratedAt, &review.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	review.Status, review.ModerationReason, review.ModeratedBy = status, reason, &moderatorID
+	return nil
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Title != "", "title", "title must be provided")
+	v.Check(len(review.Title) <= 100, "title", "title max length is 100 characters")
+
+	v.Check(review.Body != "", "body", "body must be provided")
+	v.Check(len(review.Body) <= 5000, "body", "body max length is 5000 characters")
+}
+
+// ValidateReviewDecision check a moderator decision, a rejection always has to explain itself
+func ValidateReviewDecision(v *validator.Validator, review *Review, status, reason string) {
+	v.Check(review.Status == ReviewPending, "status", "review has already been decided")
+	v.Check(status != ReviewRejected || reason != "", "reason", "reason must be provided")
+	v.Check(len(reason) <= 500, "reason", "reason max length is 500 characters")
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"time"
+)
+
+// SimilarityWeights sets how much each kind of overlap counts towards the similarity score
+type SimilarityWeights struct {
+	Genres   float64
+	Actors   float64
+	Director float64
+	Year     float64
+	// YearWindow is the year difference at which closeness in year stops counting
+	YearWindow int
+}
+
+// SimilarMovie is a movie scored against another one, Because explains the score
+type SimilarMovie struct {
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Year      int32    `json:"year"`
+	Genres    []string `json:"genres"`
+	Director  string   `json:"director"`
+	PosterURL string   `json:"poster_url"`
+	// Score is the weighted overlap between 0 and 1
+	Score   float64           `json:"score"`
+	Because SimilarityReasons `json:"because"`
+}
+
+type SimilarityReasons struct {
+	SharedGenres   []string `json:"shared_genres"`
+	SharedActors   []string `json:"shared_actors"`
+	SameDirector   bool     `json:"same_director"`
+	YearDifference int      `json:"year_difference"`
+}
+
+// Similar returns the movies scoring highest against movie. Genres and actors count by their
+// Jaccard overlap, the director when it is the same and the year by how close it is within
+// the year window. The weighted sum is divided by the total weight so scores stay within 0 and 1.
+// Only movies sharing a genre, an actor or the director are candidates, which the GIN indexed
+// arrays and the director index find without scanning the table.
+func (m *MovieModel) Similar(movie *Movie, weights SimilarityWeights, limit int) ([]*SimilarMovie, error) {
+	query := `select id, title, year, genres, director, poster_url,
+			  ($6 * genre_score + $7 * actor_score + $8 * director_score + $9 * year_score)
+				  / nullif($6 + $7 + $8 + $9, 0) as score,
+			  shared_genres, shared_actors, same_director, year_difference
+			  from (
+				  select *,
+				  coalesce(cardinality(shared_genres)::float8
+					  / nullif(cardinality(genres) + cardinality($2::text[]) - cardinality(shared_genres), 0), 0) as genre_score,
+				  coalesce(cardinality(shared_actors)::float8
+					  / nullif(cardinality(actors) + cardinality($3::text[]) - cardinality(shared_actors), 0), 0) as actor_score,
+				  same_director::int as director_score,
+				  greatest(0, 1 - year_difference::float8 / greatest($10::integer, 1)) as year_score
+				  from (
+					  select id, title, year, genres, director, actors, poster_url,
+					  array(select g from unnest(genres) as g where g = any($2)) as shared_genres,
+					  array(select a from unnest(actors) as a where lower(a) = any(lower_text_array($3))) as shared_actors,
+					  coalesce($4 <> '' and lower(director) = lower($4), false) as same_director,
+					  abs(year - $5::integer) as year_difference
+					  from movies
+					  where id <> $1 and (genres && $2 or lower_text_array(actors) && lower_text_array($3)
+						  or ($4 <> '' and lower(director) = lower($4)))
+				  ) as candidates
+			  ) as scored
+			  order by score desc, year_difference, id
+			  limit $11`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	genres := movie.Genres
+	if genres == nil {
+		genres = []string{}
+	}
+	actors := movie.Actors
+	if actors == nil {
+		actors = []string{}
+	}
+
+	args := []interface{}{
+		movie.ID,
+		pq.Array(genres),
+		pq.Array(actors),
+		movie.Director,
+		movie.Year,
+		weights.Genres,
+		weights.Actors,
+		weights.Director,
+		weights.Year,
+		weights.YearWindow,
+		limit,
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	similar := []*SimilarMovie{}
+
+	for rows.Next() {
+		var s SimilarMovie
+		var score *float64
+
+		err := rows.Scan(
+			&s.ID,
+			&s.Title,
+			&s.Year,
+			pq.Array(&s.Genres),
+			&s.Director,
+			&s.PosterURL,
+			&score,
+			pq.Array(&s.Because.SharedGenres),
+			pq.Array(&s.Because.SharedActors),
+			&s.Because.SameDirector,
+			&s.Because.YearDifference,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// All weights at zero leave the score undefined
+		if score != nil {
+			s.Score = *score
+		}
+
+		similar = append(similar, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return similar, nil
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MovieStats summarises the movies matching a MovieQuery for dashboards
+type MovieStats struct {
+	Total   int           `json:"total"`
+	Genres  []*FacetCount `json:"genres"`
+	Decades []*FacetCount `json:"decades"`
+	// AverageRuntime and MedianRuntime are in minutes, 0 without any movie
+	AverageRuntime float64       `json:"average_runtime"`
+	MedianRuntime  float64       `json:"median_runtime"`
+	TopDirectors   []*FacetCount `json:"top_directors"`
+	TopActors      []*FacetCount `json:"top_actors"`
+	GeneratedAt    time.Time     `json:"generated_at"`
+}
+
+// Stats aggregates the whole catalogue filtered by movieQuery, top limits the directors and
+// actors. It scans every matching movie several times, so it is meant to run in the background.
+func (m *MovieModel) Stats(ctx context.Context, movieQuery MovieQuery, top int) (*MovieStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	q := &queryBuilder{}
+	movieQuery.apply(q)
+	where := q.whereClause()
+
+	stats := &MovieStats{GeneratedAt: time.Now()}
+
+	query := fmt.Sprintf(`select count(*), coalesce(avg(runtime), 0),
+			  coalesce(percentile_cont(0.5) within group (order by runtime), 0)
+			  from movies %s`, where)
+
+	err := m.DB.QueryRowContext(ctx, query, q.args...).Scan(&stats.Total, &stats.AverageRuntime, &stats.MedianRuntime)
+	if err != nil {
+		return nil, err
+	}
+
+	// The genre and decade buckets are the same as the facets of the listing
+	for facet, dest := range map[string]*[]*FacetCount{"genres": &stats.Genres, "decade": &stats.Decades} {
+		facetQuery := movieFacetQueries[facet]
+
+		query := fmt.Sprintf(`select %s, count(*) from %s %s group by 1 order by %s`,
+			facetQuery.value, facetQuery.from, where, facetQuery.orderBy)
+
+		*dest, err = m.facetCounts(ctx, m.DB, query, q.args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// director and actors are kept in sync with the credits, so they hold the people's names
+	people := map[string]struct {
+		value string
+		from  string
+		dest  *[]*FacetCount
+	}{
+		"director": {"director", "movies", &stats.TopDirectors},
+		"actors":   {"actor", "movies, unnest(actors) as actor", &stats.TopActors},
+	}
+
+	for _, p := range people {
+		named := q.clone()
+		named.where(fmt.Sprintf("%s <> ''", p.value))
+
+		query := fmt.Sprintf(`select %s, count(*) from %s %s group by 1 order by 2 desc, 1 asc limit %s`,
+			p.value, p.from, named.whereClause(), named.arg(top))
+
+		*p.dest, err = m.facetCounts(ctx, m.DB, query, named.args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
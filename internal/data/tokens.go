@@ -12,7 +12,8 @@ import (
 )
 
 const (
-	ScopeActivations = "activation"
+	ScopeActivations    = "activation"
+	ScopeAuthentication = "authentication"
 )
 
 // Check that the plaintext token has been provided and is exactly 26 bytes	long.
@@ -63,11 +64,11 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 }
 
 type Token struct {
-	PlainText string
-	Hash      []byte
-	UserID    int64
-	Expiry    time.Time
-	Scope     string
+	PlainText string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -0,0 +1,248 @@
+package data
+
+import (
+	"api.go-rifqio.my.id/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LocaleRX accepts the language, script and region subtags of a BCP 47 tag like
+// "pt", "pt-BR" or "zh-Hant-TW", which is all translations are keyed by
+var LocaleRX = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z]{4})?(-([a-zA-Z]{2}|[0-9]{3}))?$`)
+
+// CanonicalLocale formats a locale the way BCP 47 recommends, lowercase language,
+// titlecase script and uppercase region. The second result is false for an invalid locale.
+func CanonicalLocale(locale string) (string, bool) {
+	if !LocaleRX.MatchString(locale) {
+		return "", false
+	}
+
+	subtags := strings.Split(locale, "-")
+	subtags[0] = strings.ToLower(subtags[0])
+
+	for i := 1; i < len(subtags); i++ {
+		if len(subtags[i]) == 4 {
+			subtags[i] = strings.ToUpper(subtags[i][:1]) + strings.ToLower(subtags[i][1:])
+		} else {
+			subtags[i] = strings.ToUpper(subtags[i])
+		}
+	}
+
+	return strings.Join(subtags, "-"), true
+}
+
+// localeLanguage returns the language subtag of a canonical locale
+func localeLanguage(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
+
+func localeLanguages(locales []string) []string {
+	languages := make([]string, len(locales))
+	for i, locale := range locales {
+		languages[i] = localeLanguage(locale)
+	}
+	return languages
+}
+
+type Translation struct {
+	MovieID   int64     `json:"movie_id"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title"`
+	Plot      string    `json:"plot"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TranslationModel struct {
+	DB *sql.DB
+}
+
+// Upsert creates the translation of a movie for a locale or replaces the existing one
+func (m *TranslationModel) Upsert(translation *Translation) error {
+	query := `insert into movie_translations (movie_id, locale, title, plot, search_config)
+			  values ($1, $2, $3, $4, locale_search_config($2))
+			  on conflict (movie_id, locale) do update
+			  set title = excluded.title, plot = excluded.plot, updated_at = now()
+			  returning created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{translation.MovieID, translation.Locale, translation.Title, translation.Plot}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&translation.CreatedAt, &translation.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNoRecordsFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *TranslationModel) GetAll(movieID int64) ([]*Translation, error) {
+	query := `select movie_id, locale, title, plot, created_at, updated_at
+			  from movie_translations where movie_id = $1
+			  order by locale`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := []*Translation{}
+
+	for rows.Next() {
+		var translation Translation
+
+		err := rows.Scan(
+			&translation.MovieID,
+			&translation.Locale,
+			&translation.Title,
+			&translation.Plot,
+			&translation.CreatedAt,
+			&translation.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		translations = append(translations, &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+func (m *TranslationModel) Delete(movieID int64, locale string) error {
+	query := `delete from movie_translations where movie_id = $1 and locale = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, locale)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+// Localize swaps the title and plot of every movie for its best translation in the preferred
+// locales, most preferred first. A locale matches its exact translation first and then any
+// translation of the same language, movies without a match keep their original title and plot.
+func (m *TranslationModel) Localize(locales []string, movies ...*Movie) error {
+	if len(locales) == 0 || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	query := `select movie_id, locale, title, plot from movie_translations
+			  where movie_id = any($1) and split_part(locale, '-', 1) = any($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(localeLanguages(locales)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	translations := make(map[int64][]*Translation)
+
+	for rows.Next() {
+		var translation Translation
+
+		err := rows.Scan(&translation.MovieID, &translation.Locale, &translation.Title, &translation.Plot)
+		if err != nil {
+			return err
+		}
+
+		translations[translation.MovieID] = append(translations[translation.MovieID], &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		translation := bestTranslation(locales, translations[movie.ID])
+		if translation == nil {
+			continue
+		}
+
+		movie.OriginalTitle = movie.Title
+		movie.Locale = translation.Locale
+		movie.Title = translation.Title
+		if translation.Plot != "" {
+			movie.Plot = translation.Plot
+		}
+	}
+
+	return nil
+}
+
+func bestTranslation(locales []string, translations []*Translation) *Translation {
+	for _, locale := range locales {
+		var sameLanguage *Translation
+
+		for _, translation := range translations {
+			if translation.Locale == locale {
+				return translation
+			}
+			// Prefer the plain language, "pt" over "pt-PT", when there is no exact match
+			if localeLanguage(translation.Locale) == localeLanguage(locale) &&
+				(sameLanguage == nil || len(translation.Locale) < len(sameLanguage.Locale)) {
+				sameLanguage = translation
+			}
+		}
+
+		if sameLanguage != nil {
+			return sameLanguage
+		}
+	}
+
+	return nil
+}
+
+func ValidateLocale(v *validator.Validator, key, locale string) {
+	v.Check(locale != "", key, key+" must be provided")
+	v.Check(LocaleRX.MatchString(locale), key, key+" must be a BCP 47 language tag like en or pt-BR")
+}
+
+func ValidateTranslation(v *validator.Validator, translation *Translation) {
+	ValidateLocale(v, "locale", translation.Locale)
+
+	v.Check(translation.Title != "", "title", "title must be provided")
+	v.Check(len(translation.Title) <= 100, "title", "title max length is 100 characters")
+	v.Check(len(translation.Plot) <= 2000, "plot", "plot max length is 2000 characters")
+}
@@ -3,12 +3,16 @@ package data
 import (
 	"api.go-rifqio.my.id/internal/validator"
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"time"
 )
 
+// AnonymousUser represents a request without a valid authentication token
+var AnonymousUser = &User{}
+
 type User struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
@@ -19,6 +23,11 @@ type User struct {
 	Version   int       `json:"-"`
 }
 
+// IsAnonymous check whether a User instance is the AnonymousUser
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
 type password struct {
 	plaintext *string
 	hash      []byte
@@ -57,17 +66,88 @@ func (m *UserModel) Insert(user *User) error {
 	return nil
 }
 
-func (m *UserModel) GetByEmail(email string) (user *User, err error) {
-	query := `select * from users where email = $1`
+func (m *UserModel) Get(id int64) (*User, error) {
+	query := `select id, created_at, name, email, password_hash, activated, version
+			  from users where id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err = m.DB.QueryRowContext(ctx, query, email).Scan(
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
 		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	query := `select id, created_at, name, email, password_hash, activated, version
+			  from users where email = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.CreatedAt,
 		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecordsFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetForToken retrieve the user owning a token of the given scope, as long as the token has not expired
+func (m *UserModel) GetForToken(tokenScope, tokenPlainText string) (*User, error) {
+	// Tokens are stored hashed, so hash the plaintext the same way before looking it up
+	tokenHash := sha256.Sum256([]byte(tokenPlainText))
+
+	query := `select users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+			  from users
+			  inner join tokens on users.id = tokens.user_id
+			  where tokens.hash = $1 and tokens.scope = $2 and tokens.expiry > $3`
+
+	args := []interface{}{tokenHash[:], tokenScope, time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
 		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
 		&user.Version,
 	)
 
@@ -78,7 +158,7 @@ func (m *UserModel) GetByEmail(email string) (user *User, err error) {
 		return nil, err
 	}
 
-	return user, nil
+	return &user, nil
 }
 
 func (p *password) Set(plaintext string) error {
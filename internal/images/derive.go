@@ -0,0 +1,79 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+)
+
+// Resize scales an image down to the given width keeping its aspect ratio and encodes
+// it as JPEG. Images already narrower than the width keep their size. Transparent
+// areas are flattened onto white since JPEG has no alpha channel.
+func Resize(src image.Image, width int) ([]byte, error) {
+	bounds := src.Bounds()
+
+	size := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+	if bounds.Dx() > width {
+		height := bounds.Dy() * width / bounds.Dx()
+		if height < 1 {
+			height = 1
+		}
+		size = image.Rect(0, 0, width, height)
+	}
+
+	dst := image.NewRGBA(size)
+	draw.Draw(dst, size, image.White, image.Point{}, draw.Src)
+
+	if size.Dx() == bounds.Dx() {
+		draw.Draw(dst, size, src, bounds.Min, draw.Over)
+	} else {
+		draw.CatmullRom.Scale(dst, size, src, bounds, draw.Over, nil)
+	}
+
+	out := new(bytes.Buffer)
+
+	err := jpeg.Encode(out, dst, &jpeg.Options{Quality: 85})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// ErrTooManyPixels is returned for images with more pixels than allowed, decoding them
+// needs far more memory than their compressed size suggests
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// CheckPixels only reads the header of the image and fails with ErrTooManyPixels when
+// its width times height is more than maxPixels
+func CheckPixels(b []byte, maxPixels int) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return ErrTooManyPixels
+	}
+
+	return nil
+}
+
+// Decode decodes any of the supported image types, images with more than maxPixels
+// pixels are rejected before any pixel is allocated
+func Decode(b []byte, maxPixels int) (image.Image, error) {
+	err := CheckPixels(b, maxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
@@ -0,0 +1,174 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http"
+)
+
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	WebP = "image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrMalformed       = errors.New("malformed image")
+)
+
+// Extensions maps the supported content types to the file extension they are stored with
+var Extensions = map[string]string{
+	JPEG: ".jpg",
+	PNG:  ".png",
+	WebP: ".webp",
+}
+
+// Sniff detects the type of an image from its content, ignoring whatever the client claimed
+func Sniff(b []byte) (string, error) {
+	contentType := http.DetectContentType(b)
+	if _, ok := Extensions[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// StripMetadata removes EXIF, XMP and text metadata, which can hold the camera
+// owner or GPS position, without decoding and re-encoding the pixels
+func StripMetadata(b []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case JPEG:
+		return stripJPEG(b)
+	case PNG:
+		return stripPNG(b)
+	case WebP:
+		return stripWebP(b)
+	}
+	return nil, ErrUnsupportedType
+}
+
+// stripJPEG copies every marker segment up to the start of scan except APP1 (EXIF and XMP)
+// and APP13 (IPTC), the entropy coded data after it is copied as is
+func stripJPEG(b []byte) ([]byte, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(b[:2])
+
+	for i := 2; ; {
+		if i+4 > len(b) || b[i] != 0xFF {
+			return nil, ErrMalformed
+		}
+
+		marker := b[i+1]
+		// Fill bytes may pad markers
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(b[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(b) {
+			return nil, ErrMalformed
+		}
+
+		if marker != 0xE1 && marker != 0xED {
+			out.Write(b[i:end])
+		}
+
+		// Start of scan, everything that follows is image data
+		if marker == 0xDA {
+			out.Write(b[end:])
+			return out.Bytes(), nil
+		}
+
+		i = end
+	}
+}
+
+// stripPNG drops the eXIf and text chunks, every chunk carries its own CRC
+// so the remaining ones stay valid
+func stripPNG(b []byte) ([]byte, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(b, signature) {
+		return nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(signature)
+
+	for i := len(signature); i < len(b); {
+		if i+8 > len(b) {
+			return nil, ErrMalformed
+		}
+
+		length := int(binary.BigEndian.Uint32(b[i:]))
+		kind := string(b[i+4 : i+8])
+		end := i + 12 + length
+		if end > len(b) {
+			return nil, ErrMalformed
+		}
+
+		switch kind {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+		default:
+			out.Write(b[i:end])
+		}
+
+		if kind == "IEND" {
+			return out.Bytes(), nil
+		}
+
+		i = end
+	}
+
+	return nil, ErrMalformed
+}
+
+// stripWebP drops the EXIF and XMP chunks of the RIFF container, clears their flags
+// in the VP8X header and rewrites the RIFF size
+func stripWebP(b []byte) ([]byte, error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(b[:12])
+
+	for i := 12; i < len(b); {
+		if i+8 > len(b) {
+			return nil, ErrMalformed
+		}
+
+		kind := string(b[i : i+4])
+		size := int(binary.LittleEndian.Uint32(b[i+4:]))
+		// Chunks are padded to an even size
+		end := i + 8 + size + size%2
+		if end > len(b) {
+			return nil, ErrMalformed
+		}
+
+		switch kind {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), b[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(b[i:end])
+		}
+
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+
+	return stripped, nil
+}
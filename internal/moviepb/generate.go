@@ -0,0 +1,5 @@
+// Package moviepb holds the protobuf messages and the gRPC client and server of
+// proto/movie/v1/movie.proto, regenerate them with go generate after changing it.
+package moviepb
+
+//go:generate protoc -I ../../proto --go_out=. --go_opt=module=api.go-rifqio.my.id/internal/moviepb --go-grpc_out=. --go-grpc_opt=module=api.go-rifqio.my.id/internal/moviepb movie/v1/movie.proto
@@ -0,0 +1,372 @@
+// Package plotindex keeps an in-memory TF-IDF index over movie plots to find
+// movies about the same things, either as another movie or as free text.
+//
+// Documents only keep their raw term counts, the inverse document frequencies
+// change with every document and are applied at query time. Memory is bounded
+// by the number of documents and the number of distinct terms kept per document.
+package plotindex
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Options struct {
+	// MaxDocuments is the number of plots kept, further documents are dropped
+	MaxDocuments int
+	// MaxTermsPerDocument keeps only the most frequent terms of long plots
+	MaxTermsPerDocument int
+	// MaxDocumentFrequency skips terms found in more than this share of the
+	// documents when looking for candidates, their weight is next to nothing anyway
+	MaxDocumentFrequency float64
+}
+
+var DefaultOptions = Options{
+	MaxDocuments:         200000,
+	MaxTermsPerDocument:  64,
+	MaxDocumentFrequency: 0.5,
+}
+
+type Match struct {
+	ID int64
+	// Score is the cosine similarity of the TF-IDF vectors, between 0 and 1
+	Score float64
+}
+
+type document struct {
+	Version int32
+	Terms   map[string]uint16
+}
+
+type Index struct {
+	mu        sync.RWMutex
+	options   Options
+	documents map[int64]document
+	postings  map[string]map[int64]struct{}
+	// changes counts the changes to the documents, saved is its value at the last
+	// save or load, so changes made while a snapshot is written keep it dirty
+	changes uint64
+	saved   uint64
+	dropped int
+	// saveMu keeps two saves from racing to rename their snapshot into place
+	saveMu sync.Mutex
+}
+
+func New(options Options) *Index {
+	return &Index{
+		options:   options,
+		documents: make(map[int64]document),
+		postings:  make(map[string]map[int64]struct{}),
+	}
+}
+
+// Put indexes the plot of a movie, replacing what was indexed for it before.
+// An empty plot removes the movie since there is nothing to compare it by.
+// A version no newer than the indexed one is ignored, so a late Put of an
+// earlier update can't overwrite a newer plot.
+func (idx *Index) Put(id int64, version int32, plot string) {
+	terms := idx.terms(plot)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if doc, ok := idx.documents[id]; ok && version <= doc.Version {
+		return
+	}
+
+	idx.remove(id)
+
+	if len(terms) == 0 {
+		return
+	}
+
+	if idx.options.MaxDocuments > 0 && len(idx.documents) >= idx.options.MaxDocuments {
+		idx.dropped++
+		return
+	}
+
+	idx.add(id, document{Version: version, Terms: terms})
+	idx.changes++
+}
+
+// Remove drops a movie from the index
+func (idx *Index) Remove(id int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *Index) add(id int64, doc document) {
+	idx.documents[id] = doc
+	for term := range doc.Terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[int64]struct{})
+		}
+		idx.postings[term][id] = struct{}{}
+	}
+}
+
+func (idx *Index) remove(id int64) {
+	doc, ok := idx.documents[id]
+	if !ok {
+		return
+	}
+
+	for term := range doc.Terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.documents, id)
+	idx.changes++
+}
+
+// Versions returns the version of every indexed movie, to find out which ones changed
+func (idx *Index) Versions() map[int64]int32 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	versions := make(map[int64]int32, len(idx.documents))
+	for id, doc := range idx.documents {
+		versions[id] = doc.Version
+	}
+	return versions
+}
+
+// Len returns the number of indexed movies and how many were dropped because the index was full
+func (idx *Index) Len() (int, int) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.documents), idx.dropped
+}
+
+// Similar returns the n movies whose plot is closest to the plot of the movie, which
+// is not part of the result. The result is empty when the movie is not indexed.
+func (idx *Index) Similar(id int64, n int) []Match {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	doc, ok := idx.documents[id]
+	if !ok {
+		return []Match{}
+	}
+
+	return idx.search(doc.Terms, id, n)
+}
+
+// Search returns the n movies whose plot is closest to free text
+func (idx *Index) Search(text string, n int) []Match {
+	terms := idx.terms(text)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.search(terms, 0, n)
+}
+
+func (idx *Index) search(query map[string]uint16, exclude int64, n int) []Match {
+	queryVector := idx.vector(query)
+	queryNorm := norm(queryVector)
+	if queryNorm == 0 {
+		return []Match{}
+	}
+
+	maxFrequency := int(idx.options.MaxDocumentFrequency * float64(len(idx.documents)))
+
+	candidates := make(map[int64]struct{})
+	for term := range query {
+		postings := idx.postings[term]
+		if idx.options.MaxDocumentFrequency > 0 && len(postings) > maxFrequency && len(postings) > 1 {
+			continue
+		}
+		for id := range postings {
+			if id != exclude {
+				candidates[id] = struct{}{}
+			}
+		}
+	}
+
+	matches := make([]Match, 0, len(candidates))
+	for id := range candidates {
+		vector := idx.vector(idx.documents[id].Terms)
+
+		var dot float64
+		for term, weight := range queryVector {
+			dot += weight * vector[term]
+		}
+
+		if dot > 0 {
+			matches = append(matches, Match{ID: id, Score: dot / (queryNorm * norm(vector))})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+
+	return matches
+}
+
+// vector weighs term counts by a sublinear term frequency and a smoothed inverse document frequency
+func (idx *Index) vector(terms map[string]uint16) map[string]float64 {
+	documents := float64(len(idx.documents))
+
+	vector := make(map[string]float64, len(terms))
+	for term, count := range terms {
+		idf := math.Log((documents+1)/(float64(len(idx.postings[term]))+1)) + 1
+		vector[term] = (1 + math.Log(float64(count))) * idf
+	}
+	return vector
+}
+
+func norm(vector map[string]float64) float64 {
+	var sum float64
+	for _, weight := range vector {
+		sum += weight * weight
+	}
+	return math.Sqrt(sum)
+}
+
+// terms splits text into lowercase words without stop words and counts them,
+// keeping the most frequent ones up to the per document limit
+func (idx *Index) terms(text string) map[string]uint16 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	counts := make(map[string]uint16)
+	for _, word := range words {
+		word = strings.TrimSuffix(strings.Trim(word, "'"), "'s")
+		if len([]rune(word)) < 3 || stopWords[word] {
+			continue
+		}
+		if counts[word] < math.MaxUint16 {
+			counts[word]++
+		}
+	}
+
+	limit := idx.options.MaxTermsPerDocument
+	if limit <= 0 || len(counts) <= limit {
+		return counts
+	}
+
+	terms := make([]string, 0, len(counts))
+	for term := range counts {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if counts[terms[i]] != counts[terms[j]] {
+			return counts[terms[i]] > counts[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+
+	kept := make(map[string]uint16, limit)
+	for _, term := range terms[:limit] {
+		kept[term] = counts[term]
+	}
+	return kept
+}
+
+// Save writes the index to a gob snapshot, through a temporary file so a crash
+// never leaves a half written snapshot behind. Nothing is written when nothing
+// changed since the last save or load. The documents are copied under the read
+// lock and written without holding it, their term maps are never modified once
+// indexed so the copy can share them.
+func (idx *Index) Save(path string) error {
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+
+	idx.mu.RLock()
+	if idx.changes == idx.saved {
+		idx.mu.RUnlock()
+		return nil
+	}
+
+	changes := idx.changes
+	documents := make(map[int64]document, len(idx.documents))
+	for id, doc := range idx.documents {
+		documents[id] = doc
+	}
+	idx.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = gob.NewEncoder(tmp).Encode(documents)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.saved = changes
+	idx.mu.Unlock()
+
+	return nil
+}
+
+// Load replaces the index with a snapshot written by Save. A missing snapshot
+// returns an error wrapping os.ErrNotExist and leaves the index as it was.
+func (idx *Index) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var documents map[int64]document
+
+	err = gob.NewDecoder(file).Decode(&documents)
+	if err != nil {
+		return fmt.Errorf("plotindex: corrupt snapshot: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.documents = make(map[int64]document, len(documents))
+	idx.postings = make(map[string]map[int64]struct{})
+	idx.dropped = 0
+
+	for id, doc := range documents {
+		if idx.options.MaxDocuments > 0 && len(idx.documents) >= idx.options.MaxDocuments {
+			idx.dropped++
+			continue
+		}
+		idx.add(id, doc)
+	}
+	idx.saved = idx.changes
+
+	return nil
+}
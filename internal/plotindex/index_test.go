@@ -0,0 +1,222 @@
+package plotindex
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Movies 1 to 3 are set in space, 1 and 2 share two terms while 1 and 3 only share
+// one. Movies 11 and 12 are about cooking and share nothing with the space movies.
+var plots = map[int64]string{
+	1:  "An astronaut crew travels to a distant planet aboard a failing spaceship.",
+	2:  "A lone astronaut stranded on a hostile planet waits for rescue.",
+	3:  "Pirates hijack a spaceship carrying a stolen engine through the galaxy.",
+	11: "A young chef opens a restaurant and fights a rival chef for a famous recipe.",
+	12: "A retired baker teaches an orphan the secret recipe of the family bakery.",
+}
+
+func fixture(options Options) *Index {
+	idx := New(options)
+	for id, plot := range plots {
+		idx.Put(id, 1, plot)
+	}
+	return idx
+}
+
+func TestSimilarOrdersByScore(t *testing.T) {
+	idx := fixture(DefaultOptions)
+
+	got := idx.Similar(1, 10)
+
+	if ids := matchIDs(got); !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Fatalf("got %v, want the movie sharing two terms before the one sharing one", ids)
+	}
+
+	for _, match := range got {
+		if match.Score <= 0 || match.Score > 1 {
+			t.Errorf("movie %d: score %v out of range", match.ID, match.Score)
+		}
+	}
+
+	if got := idx.Similar(1, 1); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("got %v, want only the closest movie", got)
+	}
+
+	if got := idx.Similar(99, 10); len(got) != 0 {
+		t.Errorf("got %v for a movie which isn't indexed, want nothing", got)
+	}
+}
+
+func TestSearchOrdersByScore(t *testing.T) {
+	idx := fixture(DefaultOptions)
+
+	tests := []struct {
+		text string
+		want []int64
+	}{
+		{"stranded astronaut", []int64{2, 1}},
+		// The possessive is dropped and 11 mentions the chef twice
+		{"chef's recipe", []int64{11, 12}},
+		{"secret recipe", []int64{12, 11}},
+		// Both mention it once, the shorter plot of 3 is closer
+		{"spaceship", []int64{3, 1}},
+		{"the and for", []int64{}},
+		{"dinosaur", []int64{}},
+	}
+
+	for _, tt := range tests {
+		if ids := matchIDs(idx.Search(tt.text, 10)); !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("search %q: got %v, want %v", tt.text, ids, tt.want)
+		}
+	}
+}
+
+func TestPutIgnoresStaleVersions(t *testing.T) {
+	idx := New(DefaultOptions)
+
+	idx.Put(1, 2, plots[11])
+	idx.Put(1, 1, plots[1])
+	idx.Put(1, 2, plots[2])
+
+	if got := matchIDs(idx.Search("astronaut", 10)); len(got) != 0 {
+		t.Errorf("got %v, want the older and same version plots ignored", got)
+	}
+
+	if got := matchIDs(idx.Search("chef", 10)); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("got %v, want the newest plot kept", got)
+	}
+
+	idx.Put(1, 3, plots[1])
+
+	if got := matchIDs(idx.Search("chef", 10)); len(got) != 0 {
+		t.Errorf("got %v, want the replaced plot gone", got)
+	}
+
+	if got := idx.Versions(); !reflect.DeepEqual(got, map[int64]int32{1: 3}) {
+		t.Errorf("got versions %v, want the newer put", got)
+	}
+
+	idx.Put(1, 4, "")
+
+	if documents, _ := idx.Len(); documents != 0 {
+		t.Errorf("got %d documents, want an empty plot to remove the movie", documents)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plots.gob")
+
+	idx := fixture(DefaultOptions)
+	if err := idx.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(DefaultOptions)
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded.Versions(), idx.Versions()) {
+		t.Errorf("got versions %v, want %v", loaded.Versions(), idx.Versions())
+	}
+
+	for id := range plots {
+		if got, want := loaded.Similar(id, 10), idx.Similar(id, 10); !sameMatches(got, want) {
+			t.Errorf("movie %d: got %v after loading, want %v", id, got, want)
+		}
+	}
+
+	// Nothing changed since the load, so there is nothing to write
+	unchanged := filepath.Join(t.TempDir(), "unchanged.gob")
+	if err := loaded.Save(unchanged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unchanged); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v, want no snapshot written for an unchanged index", err)
+	}
+
+	if err := New(DefaultOptions).Load(filepath.Join(t.TempDir(), "missing.gob")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v, want a missing snapshot to wrap os.ErrNotExist", err)
+	}
+}
+
+func TestMaxDocumentsDropsFurtherDocuments(t *testing.T) {
+	options := DefaultOptions
+	options.MaxDocuments = 2
+
+	idx := New(options)
+	idx.Put(1, 1, plots[1])
+	idx.Put(2, 1, plots[2])
+	idx.Put(3, 1, plots[3])
+
+	if documents, dropped := idx.Len(); documents != 2 || dropped != 1 {
+		t.Fatalf("got %d documents and %d dropped, want 2 and 1", documents, dropped)
+	}
+
+	// Replacing an indexed plot frees its own slot first
+	idx.Put(1, 2, plots[11])
+	if got := matchIDs(idx.Search("chef", 10)); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("got %v, want the update of an indexed movie kept in a full index", got)
+	}
+
+	// Loading a larger snapshot keeps to the limit as well
+	path := filepath.Join(t.TempDir(), "plots.gob")
+	if err := fixture(DefaultOptions).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New(options)
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if documents, dropped := loaded.Len(); documents != 2 || dropped != len(plots)-2 {
+		t.Errorf("got %d documents and %d dropped after loading, want 2 and %d", documents, dropped, len(plots)-2)
+	}
+}
+
+func TestMaxTermsPerDocumentKeepsMostFrequent(t *testing.T) {
+	options := DefaultOptions
+	options.MaxTermsPerDocument = 2
+
+	idx := New(options)
+	idx.Put(1, 1, "Dragon, dragon and dragon guard the castle of the castle knight.")
+
+	for _, term := range []string{"dragon", "castle"} {
+		if got := matchIDs(idx.Search(term, 10)); !reflect.DeepEqual(got, []int64{1}) {
+			t.Errorf("search %q: got %v, want the frequent term kept", term, got)
+		}
+	}
+
+	for _, term := range []string{"knight", "guard"} {
+		if got := matchIDs(idx.Search(term, 10)); len(got) != 0 {
+			t.Errorf("search %q: got %v, want the rare term dropped", term, got)
+		}
+	}
+}
+
+func matchIDs(matches []Match) []int64 {
+	ids := make([]int64, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	return ids
+}
+
+// sameMatches compares scores with some tolerance, they are sums over maps whose
+// order changes the last bits
+func sameMatches(a, b []Match) bool {
+	if !reflect.DeepEqual(matchIDs(a), matchIDs(b)) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i].Score-b[i].Score) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package plotindex
+
+// stopWords are common English words of three letters or more which say nothing about a plot
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, word := range []string{
+		"about", "above", "after", "again", "against", "all", "also", "and", "any", "are", "around",
+		"because", "been", "before", "being", "below", "between", "both", "but", "can", "could",
+		"did", "does", "doing", "down", "during", "each", "even", "ever", "every", "few", "for",
+		"from", "further", "had", "has", "have", "having", "her", "here", "hers", "herself", "him",
+		"himself", "his", "how", "into", "its", "itself", "just", "more", "most", "much", "must",
+		"nor", "not", "now", "off", "once", "one", "only", "other", "our", "ours", "ourselves",
+		"out", "over", "own", "same", "she", "should", "some", "such", "than", "that", "the",
+		"their", "theirs", "them", "themselves", "then", "there", "these", "they", "this", "those",
+		"through", "too", "two", "under", "until", "upon", "very", "was", "were", "what", "when",
+		"where", "which", "while", "who", "whom", "whose", "why", "will", "with", "within",
+		"without", "would", "yet", "you", "your", "yours", "yourself", "yourselves",
+	} {
+		stopWords[word] = true
+	}
+}
@@ -0,0 +1,346 @@
+// Package recommend implements item-item collaborative filtering over user ratings.
+//
+// Training compares every pair of movies rated by the same users and keeps the most
+// similar movies of each one. Recommending then blends the ratings of a single user
+// with those neighbours, so it is cheap enough to run on the request path while the
+// training runs in the background. Every step iterates in a fixed order, the same
+// ratings always give the same model and the same recommendations.
+package recommend
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	ReasonSimilar = "similar"
+	ReasonPopular = "popular"
+)
+
+type Rating struct {
+	UserID  int64
+	MovieID int64
+	Score   float64
+}
+
+type Options struct {
+	// Neighbours is the number of most similar movies kept per movie
+	Neighbours int
+	// MinOverlap is the number of users who must have rated both movies for them to be compared
+	MinOverlap int
+	// Shrinkage pulls the similarity of movies with few common users towards zero
+	Shrinkage float64
+	// Damping pulls the mean of users with few ratings towards the global mean
+	Damping float64
+	// MaxUserRatings bounds the movie pairs a single user adds during training,
+	// users with more ratings only contribute their strongest opinions
+	MaxUserRatings int
+}
+
+var DefaultOptions = Options{
+	Neighbours:     50,
+	MinOverlap:     2,
+	Shrinkage:      10,
+	Damping:        5,
+	MaxUserRatings: 500,
+}
+
+type Neighbour struct {
+	MovieID    int64
+	Similarity float64
+}
+
+// Model is the result of training, it is never modified afterwards and safe for concurrent use
+type Model struct {
+	options    Options
+	globalMean float64
+	neighbours map[int64][]Neighbour
+	// popular holds every rated movie, the most rated first
+	popular  []int64
+	averages map[int64]float64
+}
+
+type Recommendation struct {
+	MovieID int64
+	// Score is the predicted rating for similar movies and the average rating for popular ones
+	Score  float64
+	Reason string
+	// Because holds the rated movies which contributed most to a similar recommendation
+	Because []int64
+}
+
+type pair struct {
+	a, b int64
+}
+
+type pairStats struct {
+	dot     float64
+	overlap int
+}
+
+type deviation struct {
+	movieID int64
+	value   float64
+}
+
+// Train builds a model from all ratings. Similarities are the adjusted cosine of the
+// ratings, each taken as its deviation from the damped mean of the user.
+func Train(ratings []Rating, options Options) *Model {
+	sorted := append([]Rating{}, ratings...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].UserID != sorted[j].UserID {
+			return sorted[i].UserID < sorted[j].UserID
+		}
+		return sorted[i].MovieID < sorted[j].MovieID
+	})
+
+	model := &Model{
+		options:    options,
+		neighbours: make(map[int64][]Neighbour),
+		averages:   make(map[int64]float64),
+	}
+
+	var total float64
+	counts := make(map[int64]int)
+	sums := make(map[int64]float64)
+
+	for _, r := range sorted {
+		total += r.Score
+		counts[r.MovieID]++
+		sums[r.MovieID] += r.Score
+	}
+
+	if len(sorted) > 0 {
+		model.globalMean = total / float64(len(sorted))
+	}
+
+	model.popular = make([]int64, 0, len(counts))
+	for movieID, count := range counts {
+		model.popular = append(model.popular, movieID)
+		model.averages[movieID] = sums[movieID] / float64(count)
+	}
+	sort.Slice(model.popular, func(i, j int) bool {
+		a, b := model.popular[i], model.popular[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if model.averages[a] != model.averages[b] {
+			return model.averages[a] > model.averages[b]
+		}
+		return a < b
+	})
+
+	norms := make(map[int64]float64)
+	pairs := make(map[pair]*pairStats)
+
+	for start := 0; start < len(sorted); {
+		end := start
+		for end < len(sorted) && sorted[end].UserID == sorted[start].UserID {
+			end++
+		}
+
+		deviations := model.deviations(sorted[start:end])
+		start = end
+
+		for _, d := range deviations {
+			norms[d.movieID] += d.value * d.value
+		}
+
+		if options.MaxUserRatings > 0 && len(deviations) > options.MaxUserRatings {
+			strongest := append([]deviation{}, deviations...)
+			sort.SliceStable(strongest, func(i, j int) bool {
+				return math.Abs(strongest[i].value) > math.Abs(strongest[j].value)
+			})
+			deviations = strongest[:options.MaxUserRatings]
+			sort.Slice(deviations, func(i, j int) bool { return deviations[i].movieID < deviations[j].movieID })
+		}
+
+		for i := range deviations {
+			for j := i + 1; j < len(deviations); j++ {
+				key := pair{deviations[i].movieID, deviations[j].movieID}
+				stats, ok := pairs[key]
+				if !ok {
+					stats = &pairStats{}
+					pairs[key] = stats
+				}
+				stats.dot += deviations[i].value * deviations[j].value
+				stats.overlap++
+			}
+		}
+	}
+
+	for key, stats := range pairs {
+		if stats.overlap < options.MinOverlap || stats.dot <= 0 {
+			continue
+		}
+
+		similarity := stats.dot / (math.Sqrt(norms[key.a]) * math.Sqrt(norms[key.b]))
+		similarity *= float64(stats.overlap) / (float64(stats.overlap) + options.Shrinkage)
+
+		model.neighbours[key.a] = append(model.neighbours[key.a], Neighbour{key.b, similarity})
+		model.neighbours[key.b] = append(model.neighbours[key.b], Neighbour{key.a, similarity})
+	}
+
+	for movieID, neighbours := range model.neighbours {
+		sort.Slice(neighbours, func(i, j int) bool {
+			if neighbours[i].Similarity != neighbours[j].Similarity {
+				return neighbours[i].Similarity > neighbours[j].Similarity
+			}
+			return neighbours[i].MovieID < neighbours[j].MovieID
+		})
+		if options.Neighbours > 0 && len(neighbours) > options.Neighbours {
+			neighbours = neighbours[:options.Neighbours]
+		}
+		model.neighbours[movieID] = neighbours
+	}
+
+	return model
+}
+
+// userMean is the mean of the ratings of one user, pulled towards the global mean
+// so that a single rating doesn't decide what the user likes
+func (m *Model) userMean(ratings []Rating) float64 {
+	var sum float64
+	for _, r := range ratings {
+		sum += r.Score
+	}
+
+	count := float64(len(ratings)) + m.options.Damping
+	if count == 0 {
+		return m.globalMean
+	}
+
+	return (sum + m.options.Damping*m.globalMean) / count
+}
+
+// deviations returns how far every rating of one user is from the mean of the user,
+// in the order of the ratings
+func (m *Model) deviations(ratings []Rating) []deviation {
+	mean := m.userMean(ratings)
+
+	deviations := make([]deviation, len(ratings))
+	for i, r := range ratings {
+		deviations[i] = deviation{r.MovieID, r.Score - mean}
+	}
+
+	return deviations
+}
+
+// Neighbours returns the movies most similar to a movie, the most similar first
+func (m *Model) Neighbours(movieID int64) []Neighbour {
+	return append([]Neighbour{}, m.neighbours[movieID]...)
+}
+
+// Recommend returns up to n movies for a user who rated the given movies. Movies the user
+// is predicted to like more than usual come first, popular movies fill the remaining slots,
+// which makes a user without ratings get the most popular movies. Rated and excluded
+// movies are never recommended.
+func (m *Model) Recommend(ratings map[int64]float64, n int, exclude ...int64) []Recommendation {
+	skip := make(map[int64]bool, len(ratings)+len(exclude))
+	own := make([]Rating, 0, len(ratings))
+
+	for movieID, score := range ratings {
+		skip[movieID] = true
+		own = append(own, Rating{MovieID: movieID, Score: score})
+	}
+	for _, movieID := range exclude {
+		skip[movieID] = true
+	}
+
+	sort.Slice(own, func(i, j int) bool { return own[i].MovieID < own[j].MovieID })
+
+	type candidate struct {
+		movieID       int64
+		weighted      float64
+		similarity    float64
+		contributions []Neighbour
+	}
+
+	candidates := make(map[int64]*candidate)
+	var order []int64
+
+	mean := m.userMean(own)
+
+	for _, d := range m.deviations(own) {
+		for _, neighbour := range m.neighbours[d.movieID] {
+			if skip[neighbour.MovieID] {
+				continue
+			}
+
+			c, ok := candidates[neighbour.MovieID]
+			if !ok {
+				c = &candidate{movieID: neighbour.MovieID}
+				candidates[neighbour.MovieID] = c
+				order = append(order, neighbour.MovieID)
+			}
+
+			c.weighted += neighbour.Similarity * d.value
+			c.similarity += neighbour.Similarity
+			c.contributions = append(c.contributions, Neighbour{d.movieID, neighbour.Similarity * d.value})
+		}
+	}
+
+	recommendations := make([]Recommendation, 0, n)
+
+	var similar []Recommendation
+	for _, movieID := range order {
+		c := candidates[movieID]
+		// Only movies predicted above the user's own mean are worth recommending
+		if c.weighted <= 0 {
+			continue
+		}
+
+		sort.Slice(c.contributions, func(i, j int) bool {
+			if c.contributions[i].Similarity != c.contributions[j].Similarity {
+				return c.contributions[i].Similarity > c.contributions[j].Similarity
+			}
+			return c.contributions[i].MovieID < c.contributions[j].MovieID
+		})
+
+		var because []int64
+		for _, contribution := range c.contributions {
+			if contribution.Similarity <= 0 || len(because) == 3 {
+				break
+			}
+			because = append(because, contribution.MovieID)
+		}
+
+		similar = append(similar, Recommendation{
+			MovieID: movieID,
+			Score:   mean + c.weighted/c.similarity,
+			Reason:  ReasonSimilar,
+			Because: because,
+		})
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].MovieID < similar[j].MovieID
+	})
+
+	for _, r := range similar {
+		if len(recommendations) == n {
+			return recommendations
+		}
+		recommendations = append(recommendations, r)
+		skip[r.MovieID] = true
+	}
+
+	for _, movieID := range m.popular {
+		if len(recommendations) == n {
+			break
+		}
+		if skip[movieID] {
+			continue
+		}
+		recommendations = append(recommendations, Recommendation{
+			MovieID: movieID,
+			Score:   m.averages[movieID],
+			Reason:  ReasonPopular,
+		})
+	}
+
+	return recommendations
+}
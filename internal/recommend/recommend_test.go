@@ -0,0 +1,216 @@
+package recommend
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Movies 1 to 3 are science fiction and 11 to 13 romance. The first four users love
+// science fiction and dislike romance, the next four the other way around. Movie 20 is
+// rated by everyone and so the most popular, movie 21 is rated by only a few.
+var (
+	sciFi   = []int64{1, 2, 3}
+	romance = []int64{11, 12, 13}
+)
+
+func fixture() []Rating {
+	var ratings []Rating
+
+	for user := int64(1); user <= 8; user++ {
+		liked, disliked := sciFi, romance
+		if user > 4 {
+			liked, disliked = romance, sciFi
+		}
+
+		for i, movieID := range liked {
+			// Not every user rated every movie they like
+			if int64(i) == user%4 {
+				continue
+			}
+			ratings = append(ratings, Rating{user, movieID, float64(8 + (user+int64(i))%3)})
+		}
+		for _, movieID := range disliked {
+			ratings = append(ratings, Rating{user, movieID, float64(2 + user%2)})
+		}
+
+		ratings = append(ratings, Rating{user, 20, 6})
+		if user%3 == 0 {
+			ratings = append(ratings, Rating{user, 21, 9})
+		}
+	}
+
+	return ratings
+}
+
+var fixtureOptions = Options{
+	Neighbours:     10,
+	MinOverlap:     2,
+	Shrinkage:      1,
+	Damping:        1,
+	MaxUserRatings: 100,
+}
+
+func TestNeighboursStayWithinGenre(t *testing.T) {
+	model := Train(fixture(), fixtureOptions)
+
+	for _, group := range [][]int64{sciFi, romance} {
+		for _, movieID := range group {
+			neighbours := model.Neighbours(movieID)
+			if len(neighbours) < len(group)-1 {
+				t.Fatalf("movie %d: got %d neighbours, want at least the %d other movies of its genre", movieID, len(neighbours), len(group)-1)
+			}
+
+			for _, neighbour := range neighbours[:len(group)-1] {
+				if !contains(group, neighbour.MovieID) {
+					t.Errorf("movie %d: closest neighbours %v leave its genre", movieID, neighbours)
+				}
+			}
+
+			for _, neighbour := range neighbours {
+				if neighbour.Similarity <= 0 || neighbour.Similarity > 1 {
+					t.Errorf("movie %d: similarity %v out of range", movieID, neighbour)
+				}
+			}
+		}
+	}
+}
+
+func TestRecommendBlendsRatings(t *testing.T) {
+	model := Train(fixture(), fixtureOptions)
+
+	// A new user who loves the first science fiction movie and hates a romance
+	got := model.Recommend(map[int64]float64{1: 10, 11: 1}, 3)
+
+	if len(got) != 3 {
+		t.Fatalf("got %d recommendations, want 3", len(got))
+	}
+
+	for _, r := range got[:2] {
+		if r.Reason != ReasonSimilar {
+			t.Errorf("movie %d: reason %q, want %q", r.MovieID, r.Reason, ReasonSimilar)
+		}
+		if !contains(sciFi, r.MovieID) {
+			t.Errorf("recommended %d, want the other science fiction movies first", r.MovieID)
+		}
+		if len(r.Because) == 0 || r.Because[0] != 1 {
+			t.Errorf("movie %d: because %v, want it to start with the loved movie", r.MovieID, r.Because)
+		}
+	}
+
+	for _, r := range got {
+		if r.MovieID == 1 || r.MovieID == 11 {
+			t.Errorf("recommended the already rated movie %d", r.MovieID)
+		}
+		if contains(romance, r.MovieID) {
+			t.Errorf("recommended the romance %d to a user who hates romance", r.MovieID)
+		}
+	}
+}
+
+func TestRecommendColdStartFallsBackToPopularity(t *testing.T) {
+	model := Train(fixture(), fixtureOptions)
+
+	got := model.Recommend(nil, 3)
+
+	// 20 is rated by everyone, then come the genre movies which are all rated by
+	// seven users with the same average, so the lowest ids win the tie
+	want := []int64{20, 1, 2}
+
+	if ids := movieIDs(got); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+
+	for _, r := range got {
+		if r.Reason != ReasonPopular || r.Because != nil {
+			t.Errorf("movie %d: got %+v, want a popular recommendation", r.MovieID, r)
+		}
+	}
+
+	if got[0].Score != 6 {
+		t.Errorf("movie 20: score %v, want its average rating 6", got[0].Score)
+	}
+}
+
+func TestRecommendFillsWithPopularMovies(t *testing.T) {
+	model := Train(fixture(), fixtureOptions)
+
+	got := model.Recommend(map[int64]float64{1: 10, 11: 1}, 10, 20)
+
+	var popular bool
+	for _, r := range got {
+		if r.MovieID == 20 {
+			t.Errorf("recommended the excluded movie 20")
+		}
+		if r.Reason == ReasonPopular {
+			popular = true
+		} else if popular {
+			t.Errorf("similar movie %d after the popular ones", r.MovieID)
+		}
+	}
+
+	if !popular {
+		t.Errorf("got %v, want popular movies after the similar ones", movieIDs(got))
+	}
+}
+
+func TestTrainIsDeterministic(t *testing.T) {
+	ratings := fixture()
+	want := Train(ratings, fixtureOptions)
+
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10; i++ {
+		shuffled := append([]Rating{}, ratings...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		got := Train(shuffled, fixtureOptions)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("training on shuffled ratings gave a different model")
+		}
+
+		user := map[int64]float64{2: 9, 12: 3, 21: 8}
+		if !reflect.DeepEqual(got.Recommend(user, 5), want.Recommend(user, 5)) {
+			t.Fatalf("recommendations differ between identical models")
+		}
+	}
+}
+
+func TestMaxUserRatingsBoundsPairs(t *testing.T) {
+	options := fixtureOptions
+	options.MaxUserRatings = 1
+
+	// With a single opinion per user no pair of movies is ever rated together
+	model := Train(fixture(), options)
+
+	for _, movieID := range append(append([]int64{}, sciFi...), romance...) {
+		if neighbours := model.Neighbours(movieID); len(neighbours) != 0 {
+			t.Errorf("movie %d: got neighbours %v, want none", movieID, neighbours)
+		}
+	}
+}
+
+func TestTrainWithoutRatings(t *testing.T) {
+	model := Train(nil, DefaultOptions)
+
+	if got := model.Recommend(map[int64]float64{1: 10}, 5); len(got) != 0 {
+		t.Fatalf("got %v, want no recommendations", got)
+	}
+}
+
+func contains(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func movieIDs(recommendations []Recommendation) []int64 {
+	ids := make([]int64, len(recommendations))
+	for i, r := range recommendations {
+		ids[i] = r.MovieID
+	}
+	return ids
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage keeps uploaded files by key. Keys are slash separated relative paths
+// like "posters/12/3f9a0c.jpg". The local filesystem implementation is used for
+// now, an S3 compatible backend only has to satisfy the same interface.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients can download the object
+	URL(key string) string
+}
+
+// Object is a stored file opened for reading, the caller has to close the body
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS movies_created_at_idx;
+
+DROP INDEX IF EXISTS movies_actors_idx;
+
+DROP INDEX IF EXISTS movies_director_idx;
+
+DROP INDEX IF EXISTS movies_runtime_idx;
+
+DROP INDEX IF EXISTS movies_year_idx;
+
+DROP FUNCTION IF EXISTS lower_text_array(text[]);
@@ -0,0 +1,13 @@
+CREATE OR REPLACE FUNCTION lower_text_array(text[]) RETURNS text[]
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT array_agg(lower(value)) FROM unnest($1) AS value $$;
+
+CREATE INDEX IF NOT EXISTS movies_year_idx ON movies (year);
+
+CREATE INDEX IF NOT EXISTS movies_runtime_idx ON movies (runtime);
+
+CREATE INDEX IF NOT EXISTS movies_director_idx ON movies (lower(director));
+
+CREATE INDEX IF NOT EXISTS movies_actors_idx ON movies USING GIN (lower_text_array(actors));
+
+CREATE INDEX IF NOT EXISTS movies_created_at_idx ON movies (created_at);
@@ -0,0 +1,13 @@
+-- array_to_string() is only marked as stable, wrap it so that it can be used in a generated column
+CREATE OR REPLACE FUNCTION immutable_array_to_string(text[], text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT array_to_string($1, $2) $$;
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', director), 'B') ||
+    setweight(to_tsvector('simple', immutable_array_to_string(actors, ' ')), 'B') ||
+    setweight(to_tsvector('simple', plot), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_search_vector_idx ON movies USING GIN (search_vector);
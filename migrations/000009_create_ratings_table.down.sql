@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS ratings_sync_movie ON ratings;
+
+DROP FUNCTION IF EXISTS sync_movie_rating();
+
+DROP INDEX IF EXISTS movies_rating_average_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_average;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_total;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+
+DROP TABLE IF EXISTS ratings;
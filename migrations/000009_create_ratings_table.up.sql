@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS ratings (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    score integer NOT NULL CHECK (score BETWEEN 1 AND 10),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS ratings_movie_id_idx ON ratings (movie_id);
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_total bigint NOT NULL DEFAULT 0;
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_average double precision GENERATED ALWAYS AS (
+    CASE WHEN rating_count = 0 THEN 0 ELSE rating_total::double precision / rating_count END
+) STORED;
+
+CREATE INDEX IF NOT EXISTS movies_rating_average_idx ON movies (rating_average);
+
+-- Keep the aggregate columns of movies in sync with every rating change,
+-- the old score is taken out and the new score is added in
+CREATE OR REPLACE FUNCTION sync_movie_rating() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE movies SET rating_count = rating_count - 1, rating_total = rating_total - OLD.score
+        WHERE id = OLD.movie_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE movies SET rating_count = rating_count + 1, rating_total = rating_total + NEW.score
+        WHERE id = NEW.movie_id;
+    END IF;
+
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER ratings_sync_movie
+    AFTER INSERT OR UPDATE OR DELETE ON ratings
+    FOR EACH ROW EXECUTE FUNCTION sync_movie_rating();
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS movie_lists (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    list text NOT NULL CHECK (list IN ('watchlist', 'favourites')),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    watched bool NOT NULL DEFAULT false,
+    watched_at timestamp(0) with time zone,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, list, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_lists_added_at_idx ON movie_lists (user_id, list, added_at);
+
+CREATE INDEX IF NOT EXISTS movie_lists_movie_id_idx ON movie_lists (movie_id);
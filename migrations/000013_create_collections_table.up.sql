@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS collections (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    title text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    visibility text NOT NULL DEFAULT 'private' CHECK (visibility IN ('public', 'private', 'unlisted')),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS collections_user_id_idx ON collections (user_id);
+
+CREATE INDEX IF NOT EXISTS collections_public_title_idx ON collections USING GIN (to_tsvector('simple', title))
+    WHERE visibility = 'public';
+
+-- The position constraint is only checked on commit, so reordering can shift
+-- positions one statement at a time inside a transaction
+CREATE TABLE IF NOT EXISTS collection_movies (
+    collection_id bigint NOT NULL REFERENCES collections ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    position integer NOT NULL CHECK (position > 0),
+    note text NOT NULL DEFAULT '',
+    added_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (collection_id, movie_id),
+    CONSTRAINT collection_movies_position_key UNIQUE (collection_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX IF NOT EXISTS collection_movies_movie_id_idx ON collection_movies (movie_id);
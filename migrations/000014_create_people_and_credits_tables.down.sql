@@ -0,0 +1,15 @@
+DELETE FROM permissions WHERE code = 'movies:write';
+
+DROP TRIGGER IF EXISTS people_sync_movie_people ON people;
+
+DROP FUNCTION IF EXISTS sync_movie_people_from_person();
+
+DROP TRIGGER IF EXISTS credits_sync_movie_people ON credits;
+
+DROP FUNCTION IF EXISTS sync_movie_people_from_credits();
+
+DROP FUNCTION IF EXISTS refresh_movie_people(bigint);
+
+DROP TABLE IF EXISTS credits;
+
+DROP TABLE IF EXISTS people;
@@ -0,0 +1,98 @@
+CREATE TABLE IF NOT EXISTS people (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS people_name_idx ON people (lower(name));
+
+CREATE INDEX IF NOT EXISTS people_name_trgm_idx ON people USING GIN (lower(name) gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS credits (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    person_id bigint NOT NULL REFERENCES people ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('director', 'actor', 'writer')),
+    character_name text NOT NULL DEFAULT '',
+    billing_order integer NOT NULL DEFAULT 0,
+    UNIQUE (movie_id, person_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS credits_movie_id_idx ON credits (movie_id, role, billing_order);
+
+CREATE INDEX IF NOT EXISTS credits_person_id_idx ON credits (person_id);
+
+-- Backfill one person per distinct name from the free text columns, then credit them
+INSERT INTO people (name)
+SELECT DISTINCT ON (lower(name)) name
+FROM (SELECT director AS name FROM movies UNION ALL SELECT unnest(actors) FROM movies) AS names
+WHERE name <> ''
+ORDER BY lower(name), name;
+
+INSERT INTO credits (movie_id, person_id, role, billing_order)
+SELECT movies.id, people.id, 'director', 0
+FROM movies INNER JOIN people ON lower(people.name) = lower(movies.director)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO credits (movie_id, person_id, role, billing_order)
+SELECT movies.id, people.id, 'actor', actor.billing_order
+FROM movies
+CROSS JOIN LATERAL unnest(movies.actors) WITH ORDINALITY AS actor(name, billing_order)
+INNER JOIN people ON lower(people.name) = lower(actor.name)
+ON CONFLICT DO NOTHING;
+
+-- movies.director and movies.actors stay as a copy of the credits, so existing
+-- responses and the indexes built on them keep working. They are rebuilt
+-- whenever a credit changes or a credited person is renamed.
+CREATE OR REPLACE FUNCTION refresh_movie_people(movie bigint) RETURNS void
+    LANGUAGE sql
+    AS $$
+UPDATE movies SET
+    director = coalesce((
+        SELECT people.name FROM credits INNER JOIN people ON people.id = credits.person_id
+        WHERE credits.movie_id = movie AND credits.role = 'director'
+        ORDER BY credits.billing_order, credits.id LIMIT 1
+    ), ''),
+    actors = array(
+        SELECT people.name FROM credits INNER JOIN people ON people.id = credits.person_id
+        WHERE credits.movie_id = movie AND credits.role = 'actor'
+        ORDER BY credits.billing_order, credits.id
+    )
+WHERE id = movie
+$$;
+
+CREATE OR REPLACE FUNCTION sync_movie_people_from_credits() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM refresh_movie_people(OLD.movie_id);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM refresh_movie_people(NEW.movie_id);
+    END IF;
+
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER credits_sync_movie_people
+    AFTER INSERT OR UPDATE OR DELETE ON credits
+    FOR EACH ROW EXECUTE FUNCTION sync_movie_people_from_credits();
+
+CREATE OR REPLACE FUNCTION sync_movie_people_from_person() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    PERFORM refresh_movie_people(movie_id) FROM credits WHERE person_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER people_sync_movie_people
+    AFTER UPDATE OF name ON people
+    FOR EACH ROW EXECUTE FUNCTION sync_movie_people_from_person();
+
+INSERT INTO permissions (code) VALUES ('movies:write') ON CONFLICT DO NOTHING;
@@ -0,0 +1,9 @@
+DELETE FROM permissions WHERE code = 'genres:manage';
+
+DROP FUNCTION IF EXISTS resolve_genres(text[]);
+
+DROP TABLE IF EXISTS genres;
+
+DROP FUNCTION IF EXISTS genre_slug(text);
+
+DROP EXTENSION IF EXISTS unaccent;
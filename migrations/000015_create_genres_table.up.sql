@@ -0,0 +1,56 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- genre_slug turns a free text genre into its slug, it must stay in line with data.Slugify.
+-- Only punctuation and spaces are dropped, so a non-Latin genre doesn't slugify to nothing.
+-- unaccent() is only stable, naming its dictionary lets genre_slug be immutable.
+CREATE OR REPLACE FUNCTION genre_slug(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$ SELECT trim(both '-' FROM regexp_replace(lower(unaccent('unaccent', $1)), '[[:punct:][:space:]]+', '-', 'g')) $$;
+
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    aliases text[] NOT NULL DEFAULT '{}',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING GIN (aliases);
+
+-- resolve_genres maps genre names, slugs and aliases to their slug, unknown genres are only slugified
+CREATE OR REPLACE FUNCTION resolve_genres(text[]) RETURNS text[]
+    LANGUAGE sql STABLE PARALLEL SAFE
+    AS $$
+SELECT array_agg(coalesce(
+    (SELECT genres.slug FROM genres WHERE genres.slug = genre_slug(value) OR genre_slug(value) = ANY (genres.aliases)),
+    genre_slug(value)
+) ORDER BY position)
+FROM unnest($1) WITH ORDINALITY AS input(value, position)
+$$;
+
+-- Seed one genre per slug already in use, named after its most common spelling
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (genre_slug(value)) genre_slug(value),
+    CASE WHEN value = lower(value) THEN initcap(value) ELSE value END
+FROM (SELECT unnest(genres) AS value FROM movies) AS used
+WHERE genre_slug(value) <> ''
+GROUP BY value
+ORDER BY genre_slug(value), count(*) DESC, value
+ON CONFLICT (slug) DO NOTHING;
+
+-- Store every movie genre as its slug, keeping the first occurrence of duplicates. A movie
+-- whose genres all slugify to nothing keeps them as they are, an empty array would break
+-- genres_length_check and the genres have to be fixed by hand.
+UPDATE movies SET genres = array(
+    SELECT slug FROM (
+        SELECT genre_slug(value) AS slug, min(position) AS position
+        FROM unnest(movies.genres) WITH ORDINALITY AS genre(value, position)
+        WHERE genre_slug(value) <> ''
+        GROUP BY 1
+    ) AS normalised
+    ORDER BY position
+)
+WHERE EXISTS (SELECT 1 FROM unnest(movies.genres) AS value WHERE genre_slug(value) <> '');
+
+INSERT INTO permissions (code) VALUES ('genres:manage') ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+-- poster_key is the storage key of an uploaded poster, empty while poster_url points elsewhere
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS poster_key text NOT NULL DEFAULT '';
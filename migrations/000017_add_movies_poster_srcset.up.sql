@@ -0,0 +1,2 @@
+-- poster_srcset maps the derived poster sizes like w92 to their URL, filled in the background after an upload
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS poster_srcset jsonb NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS movie_translations;
+
+DROP FUNCTION IF EXISTS locale_search_config(text);
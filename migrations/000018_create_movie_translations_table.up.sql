@@ -0,0 +1,51 @@
+-- locale_search_config picks the text search configuration for the language of a
+-- BCP 47 locale, languages without a configuration are searched with 'simple'
+CREATE OR REPLACE FUNCTION locale_search_config(text) RETURNS regconfig
+    LANGUAGE sql STABLE PARALLEL SAFE
+    AS $$
+SELECT CASE lower(split_part($1, '-', 1))
+    WHEN 'ar' THEN 'arabic'
+    WHEN 'da' THEN 'danish'
+    WHEN 'de' THEN 'german'
+    WHEN 'en' THEN 'english'
+    WHEN 'es' THEN 'spanish'
+    WHEN 'fi' THEN 'finnish'
+    WHEN 'fr' THEN 'french'
+    WHEN 'ga' THEN 'irish'
+    WHEN 'hu' THEN 'hungarian'
+    WHEN 'id' THEN 'indonesian'
+    WHEN 'it' THEN 'italian'
+    WHEN 'lt' THEN 'lithuanian'
+    WHEN 'nb' THEN 'norwegian'
+    WHEN 'ne' THEN 'nepali'
+    WHEN 'nl' THEN 'dutch'
+    WHEN 'nn' THEN 'norwegian'
+    WHEN 'no' THEN 'norwegian'
+    WHEN 'pt' THEN 'portuguese'
+    WHEN 'ro' THEN 'romanian'
+    WHEN 'ru' THEN 'russian'
+    WHEN 'sv' THEN 'swedish'
+    WHEN 'ta' THEN 'tamil'
+    WHEN 'tr' THEN 'turkish'
+    ELSE 'simple'
+END::regconfig
+$$;
+
+CREATE TABLE IF NOT EXISTS movie_translations (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    locale text NOT NULL,
+    title text NOT NULL,
+    plot text NOT NULL DEFAULT '',
+    search_config regconfig NOT NULL,
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_config, title), 'A') ||
+        setweight(to_tsvector(search_config, plot), 'C')
+    ) STORED,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (movie_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS movie_translations_language_idx ON movie_translations (split_part(locale, '-', 1), movie_id);
+
+CREATE INDEX IF NOT EXISTS movie_translations_search_vector_idx ON movie_translations USING GIN (search_vector);
@@ -0,0 +1,11 @@
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_year_check;
+
+ALTER TABLE movies ADD CONSTRAINT movies_year_check CHECK (year BETWEEN 1900 AND date_part('year', CURRENT_DATE)) NOT VALID;
+
+DROP TABLE IF EXISTS releases;
+
+DROP FUNCTION IF EXISTS sync_movie_first_release();
+
+ALTER TABLE movies DROP COLUMN IF EXISTS first_release_date;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS release_years;
@@ -0,0 +1,58 @@
+CREATE TABLE IF NOT EXISTS releases (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    country char(2) NOT NULL CHECK (country ~ '^[A-Z]{2}$'),
+    release_date date NOT NULL,
+    release_type text NOT NULL CHECK (release_type IN ('theatrical', 'digital', 'festival')),
+    certification text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    version integer NOT NULL DEFAULT 1,
+    UNIQUE (movie_id, country, release_type)
+);
+
+CREATE INDEX IF NOT EXISTS releases_release_date_idx ON releases (release_date, id);
+
+CREATE INDEX IF NOT EXISTS releases_country_idx ON releases (country, lower(certification));
+
+-- first_release_date is the earliest release of a movie anywhere and release_years the
+-- years it is released in, both kept in sync with the releases
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS first_release_date date;
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS release_years integer[] NOT NULL DEFAULT '{}';
+
+CREATE OR REPLACE FUNCTION sync_movie_first_release() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE movies SET
+            first_release_date = (SELECT min(release_date) FROM releases WHERE movie_id = OLD.movie_id),
+            release_years = array(SELECT DISTINCT date_part('year', release_date)::integer FROM releases WHERE movie_id = OLD.movie_id)
+        WHERE id = OLD.movie_id;
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE movies SET
+            first_release_date = (SELECT min(release_date) FROM releases WHERE movie_id = NEW.movie_id),
+            release_years = array(SELECT DISTINCT date_part('year', release_date)::integer FROM releases WHERE movie_id = NEW.movie_id)
+        WHERE id = NEW.movie_id;
+    END IF;
+
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER releases_sync_movie
+    AFTER INSERT OR UPDATE OR DELETE ON releases
+    FOR EACH ROW EXECUTE FUNCTION sync_movie_first_release();
+
+-- A movie without releases may be catalogued up to 5 years ahead, once it has releases
+-- a future year needs a release dated in that year
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_year_check;
+
+ALTER TABLE movies ADD CONSTRAINT movies_year_check
+    CHECK (year >= 1900 AND (
+        year <= date_part('year', CURRENT_DATE)
+        OR cardinality(release_years) = 0 AND year <= date_part('year', CURRENT_DATE) + 5
+        OR year = ANY (release_years)
+    ));
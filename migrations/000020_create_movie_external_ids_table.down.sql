@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS movie_external_ids;
+
+DROP FUNCTION IF EXISTS sync_movie_external_ids();
+
+DROP FUNCTION IF EXISTS refresh_movie_external_ids(bigint);
+
+ALTER TABLE movies DROP COLUMN IF EXISTS external_ids;
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source text NOT NULL CHECK (source IN ('imdb', 'tmdb', 'wikidata')),
+    external_id text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (source, external_id),
+    UNIQUE (movie_id, source)
+);
+
+-- external_ids keeps a copy of the mapping on the movie so it is read along with the movie
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_ids jsonb NOT NULL DEFAULT '{}';
+
+CREATE OR REPLACE FUNCTION refresh_movie_external_ids(movie bigint) RETURNS void
+    LANGUAGE sql
+    AS $$
+UPDATE movies SET external_ids = coalesce((
+    SELECT jsonb_object_agg(source, external_id) FROM movie_external_ids WHERE movie_id = movie
+), '{}')
+WHERE id = movie
+$$;
+
+CREATE OR REPLACE FUNCTION sync_movie_external_ids() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM refresh_movie_external_ids(OLD.movie_id);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM refresh_movie_external_ids(NEW.movie_id);
+    END IF;
+
+    RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER movie_external_ids_sync_movie
+    AFTER INSERT OR UPDATE OR DELETE ON movie_external_ids
+    FOR EACH ROW EXECUTE FUNCTION sync_movie_external_ids();
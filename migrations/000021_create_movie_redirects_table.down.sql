@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'movies:merge';
+
+DROP TABLE IF EXISTS movie_redirects;
+
+DROP FUNCTION IF EXISTS normalize_title(text);
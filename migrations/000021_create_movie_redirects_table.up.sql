@@ -0,0 +1,22 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- normalize_title reduces a title to what duplicates have in common: lowercase letters
+-- of any script without their accents, digits, single spaces and no leading article.
+-- Only punctuation and spaces are dropped, a title in a non-Latin script keeps its letters.
+-- unaccent() is only stable, naming its dictionary lets normalize_title be immutable.
+CREATE OR REPLACE FUNCTION normalize_title(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE
+    AS $$
+SELECT regexp_replace(trim(regexp_replace(lower(unaccent('unaccent', $1)), '[[:punct:][:space:]]+', ' ', 'g')), '^(the|a|an) ', '')
+$$;
+
+-- A merged movie leaves a redirect behind so its old id keeps resolving
+CREATE TABLE IF NOT EXISTS movie_redirects (
+    old_id bigint PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS movie_redirects_movie_id_idx ON movie_redirects (movie_id);
+
+INSERT INTO permissions (code) VALUES ('movies:merge') ON CONFLICT DO NOTHING;